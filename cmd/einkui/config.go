@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"nas-kit-ui/pkg/epd"
+	"nas-kit-ui/pkg/fan"
+	"nas-kit-ui/pkg/led"
+	"strings"
+	"time"
+)
+
+const (
+	pageTypeDisks = "disks"
+	pageTypeLoad  = "load"
+)
+
+// appConfig is the json config file of the ui, the flags override it
+type appConfig struct {
+	Panel     string `json:"panel"`
+	Font      string `json:"font"`
+	IndexPage string `json:"index_page"`
+	// StateFile keeps the state of the ui across the restarts, the ui starts from the index page if empty
+	StateFile string `json:"state_file"`
+	// FinalTitle and FinalText are shown while the panel sleeps after the ui stops, the last page stays if no text
+	FinalTitle string       `json:"final_title"`
+	FinalText  []string     `json:"final_text"`
+	Pages      []pageConfig `json:"pages"`
+	Menu       menuConfig   `json:"menu"`
+	Fan        fanConfig    `json:"fan"`
+	Led        ledConfig    `json:"led"`
+}
+
+type pageConfig struct {
+	// Type is disks for the usage of one or two disks or load for the cpu and ram usage
+	Type string `json:"type"`
+	// Name identifies the page e.g. as the index page
+	Name string `json:"name"`
+	// Label is the page header, the name if empty
+	Label           string   `json:"label"`
+	Disks           []string `json:"disks"`
+	RefreshInterval float64  `json:"refresh_interval"`
+}
+
+type menuConfig struct {
+	Label   string           `json:"label"`
+	PerPage int              `json:"per_page"`
+	Items   []menuItemConfig `json:"items"`
+	// ConfirmTimeout cancels the confirmation of reboot and power off when not answered in time, 0 waits
+	ConfirmTimeout duration `json:"confirm_timeout"`
+}
+
+type menuItemConfig struct {
+	// Action is one of the menuActions
+	Action string `json:"action"`
+	Label  string `json:"label"`
+	// Items make the item a submenu, it has no action then
+	Items []menuItemConfig `json:"items"`
+	// Setting is one of the settingItems edited by the item, it has no action then
+	Setting string `json:"setting"`
+}
+
+type fanConfig struct {
+	Enabled bool `json:"enabled"`
+	// Mode is curve or pid
+	Mode       string   `json:"mode"`
+	Curve      string   `json:"curve"`
+	Hysteresis float64  `json:"hysteresis"`
+	MinPercent float64  `json:"min_percent"`
+	SpinUp     duration `json:"spinup"`
+	Target     float64  `json:"target"`
+	Kp         float64  `json:"kp"`
+	Ki         float64  `json:"ki"`
+	Kd         float64  `json:"kd"`
+	Interval   duration `json:"interval"`
+}
+
+type ledConfig struct {
+	Enabled bool `json:"enabled"`
+	// DiskFull is the disk usage percent from which the led shows the full disk
+	DiskFull float64 `json:"disk_full"`
+	// Rules are in state=pattern form, they win over the default ones
+	Rules []string `json:"rules"`
+}
+
+// duration is a time.Duration written as a string in json e.g. "2s"
+type duration struct {
+	time.Duration
+}
+
+// defaultAppConfig returns the config of the ui without the config file
+func defaultAppConfig() appConfig {
+	curve := fan.NewCurveController()
+	pid := fan.NewPIDController(55)
+
+	return appConfig{
+		Panel:      epd.DefaultConfig().Panel,
+		Font:       "JetBrainsMono-Regular.ttf",
+		FinalTitle: "NAS-Kit",
+		FinalText:  []string{"UI stopped"},
+		Pages: []pageConfig{
+			{Type: pageTypeLoad, Name: "Load", Label: "Usage", RefreshInterval: 0.5},
+		},
+		Menu: menuConfig{
+			Label:          "Menu",
+			ConfirmTimeout: duration{10 * time.Second},
+			Items: []menuItemConfig{
+				{Action: "reboot", Label: "Reboot Device"},
+				{Action: "poweroff", Label: "Power off"},
+				{Action: "uptime", Label: "Uptime"},
+				{Label: "Settings", Items: []menuItemConfig{
+					{Setting: "index_page", Label: "Index page"},
+					{Setting: "refresh_interval", Label: "Refresh"},
+					{Setting: "fan_mode", Label: "Fan mode"},
+					{Setting: "fan_target", Label: "Fan target"},
+					{Setting: "led", Label: "Led"},
+				}},
+				{Action: "clear", Label: "Clear screen"},
+				{Action: "exit", Label: "Exit"},
+			},
+		},
+		Fan: fanConfig{
+			Enabled:    true,
+			Mode:       "curve",
+			Curve:      "45:0,50:40,60:70,70:100",
+			Hysteresis: curve.Hysteresis,
+			MinPercent: curve.MinDuty * 100,
+			SpinUp:     duration{curve.SpinUp},
+			Target:     pid.Target,
+			Kp:         pid.Kp,
+			Ki:         pid.Ki,
+			Kd:         pid.Kd,
+			Interval:   duration{2 * time.Second},
+		},
+		Led: ledConfig{
+			Enabled:  true,
+			DiskFull: 90,
+		},
+	}
+}
+
+// loadAppConfig reads the config file over the defaults, unknown fields are reported as errors
+func loadAppConfig(path string) (appConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return defaultAppConfig(), err
+	}
+
+	cfg, err := decodeAppConfig(data)
+	if err != nil {
+		return cfg, fmt.Errorf("config %s: %v", path, err)
+	}
+
+	return cfg, nil
+}
+
+// decodeAppConfig decodes the json config over the defaults
+func decodeAppConfig(data []byte) (appConfig, error) {
+	defaults := defaultAppConfig()
+
+	// json decodes the arrays into the elements of the slices, so the default lists are not
+	// merged with the ones of the file but only used when the file has none
+	cfg := defaults
+	cfg.Pages = nil
+	cfg.Menu.Items = nil
+	cfg.Led.Rules = nil
+	cfg.FinalText = nil
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(&cfg)
+	if err != nil {
+		return defaults, err
+	}
+
+	if cfg.Pages == nil {
+		cfg.Pages = defaults.Pages
+	}
+
+	if cfg.Menu.Items == nil {
+		cfg.Menu.Items = defaults.Menu.Items
+	}
+
+	if cfg.Led.Rules == nil {
+		cfg.Led.Rules = defaults.Led.Rules
+	}
+
+	if cfg.FinalText == nil {
+		cfg.FinalText = defaults.FinalText
+	}
+
+	return cfg, nil
+}
+
+// diskPages returns the disk pages for the disks, two disks per page if grouped
+func diskPages(disks []string, group bool) []pageConfig {
+	var pages []pageConfig
+
+	for i := 0; i < len(disks); {
+		n := 1
+		if group && i+1 < len(disks) {
+			n = 2
+		}
+
+		name := fmt.Sprintf("Disk %d", i+1)
+		if n == 2 {
+			name = fmt.Sprintf("Disk %d&%d", i+1, i+2)
+		}
+
+		pages = append(pages, pageConfig{
+			Type:            pageTypeDisks,
+			Name:            name,
+			Disks:           disks[i : i+n],
+			RefreshInterval: 0.8,
+		})
+
+		i += n
+	}
+
+	return pages
+}
+
+// replaceDiskPages puts the pages in place of the disk pages of the config, the other pages follow them
+func (c *appConfig) replaceDiskPages(pages []pageConfig) {
+	for _, page := range c.Pages {
+		if page.Type != pageTypeDisks {
+			pages = append(pages, page)
+		}
+	}
+
+	c.Pages = pages
+}
+
+// setRefreshIntervals sets the intervals of the pages by the page name, the other pages keep theirs
+func (c *appConfig) setRefreshIntervals(intervals map[string]float64) {
+	if len(intervals) == 0 {
+		return
+	}
+
+	pages := append([]pageConfig{}, c.Pages...)
+
+	for i, page := range pages {
+		if interval, ok := intervals[page.Name]; ok {
+			pages[i].RefreshInterval = interval
+		}
+	}
+
+	c.Pages = pages
+}
+
+// Validate checks the whole config and reports the first error with the path to the wrong value
+func (c appConfig) Validate() error {
+	if _, err := epd.LookupPanel(c.Panel); err != nil {
+		return err
+	}
+
+	if len(c.Pages) == 0 {
+		return errors.New("config: no pages")
+	}
+
+	names := map[string]bool{}
+	disks := 0
+
+	for i, page := range c.Pages {
+		err := page.validate()
+		if err != nil {
+			return fmt.Errorf("config: pages[%d]: %v", i, err)
+		}
+
+		if names[page.Name] {
+			return fmt.Errorf("config: pages[%d]: duplicate page name \"%s\"", i, page.Name)
+		}
+
+		names[page.Name] = true
+		disks += len(page.Disks)
+	}
+
+	if disks == 0 {
+		return errors.New("no partition(s) specified, use -d or the disks pages of the config")
+	}
+
+	if c.IndexPage != "" && !names[c.IndexPage] {
+		return fmt.Errorf("config: index_page: no page named \"%s\"", c.IndexPage)
+	}
+
+	if c.Menu.PerPage < 0 {
+		return errors.New("config: menu: per_page must not be negative")
+	}
+
+	if c.Menu.ConfirmTimeout.Duration < 0 {
+		return errors.New("config: menu: confirm_timeout must not be negative")
+	}
+
+	if err := validateMenuItems(c.Menu.Items); err != nil {
+		return fmt.Errorf("config: menu: %v", err)
+	}
+
+	if c.Fan.Enabled {
+		if _, err := createFanController(c.Fan); err != nil {
+			return fmt.Errorf("config: fan: %v", err)
+		}
+
+		if c.Fan.Interval.Duration <= 0 {
+			return errors.New("config: fan: interval must be positive")
+		}
+	}
+
+	if c.Led.DiskFull < 0 || c.Led.DiskFull > 100 {
+		return errors.New("config: led: disk_full must be in 0-100 range")
+	}
+
+	if _, err := c.Led.rules(); err != nil {
+		return fmt.Errorf("config: led: %v", err)
+	}
+
+	return nil
+}
+
+// validateMenuItems checks the items and their submenus, the error has the path to the wrong item
+func validateMenuItems(items []menuItemConfig) error {
+	for i, item := range items {
+		if item.Label == "" {
+			return fmt.Errorf("items[%d]: no label", i)
+		}
+
+		if len(item.Items) > 0 {
+			if item.Action != "" || item.Setting != "" {
+				return fmt.Errorf("items[%d]: a submenu has no action or setting", i)
+			}
+
+			if err := validateMenuItems(item.Items); err != nil {
+				return fmt.Errorf("items[%d]: %v", i, err)
+			}
+
+			continue
+		}
+
+		if item.Setting != "" {
+			if item.Action != "" {
+				return fmt.Errorf("items[%d]: a setting has no action", i)
+			}
+
+			if _, ok := settingItems[item.Setting]; !ok {
+				return fmt.Errorf("items[%d]: unknown setting \"%s\", use one of: %s", i, item.Setting, strings.Join(settingNames(), ", "))
+			}
+
+			continue
+		}
+
+		if _, ok := menuActions[item.Action]; !ok {
+			return fmt.Errorf("items[%d]: unknown action \"%s\", use one of: %s", i, item.Action, strings.Join(menuActionNames(), ", "))
+		}
+	}
+
+	return nil
+}
+
+func (p pageConfig) validate() error {
+	if p.Name == "" {
+		return errors.New("no name")
+	}
+
+	if p.RefreshInterval < 0 {
+		return errors.New("refresh_interval must not be negative")
+	}
+
+	switch p.Type {
+	case pageTypeDisks:
+		if len(p.Disks) < 1 || len(p.Disks) > 2 {
+			return errors.New("a disks page shows one or two disks")
+		}
+	case pageTypeLoad:
+		if len(p.Disks) > 0 {
+			return errors.New("a load page has no disks")
+		}
+	default:
+		return fmt.Errorf("unknown type \"%s\", use one of: %s, %s", p.Type, pageTypeDisks, pageTypeLoad)
+	}
+
+	return nil
+}
+
+func (p pageConfig) label() string {
+	if p.Label != "" {
+		return p.Label
+	}
+
+	return p.Name
+}
+
+// rules returns the led rules of the config followed by the default ones
+func (l ledConfig) rules() ([]led.Rule, error) {
+	var rules []led.Rule
+
+	for _, s := range l.Rules {
+		rule, err := led.ParseRule(s)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return append(rules, led.DefaultRules...), nil
+}
+
+// createFanController creates the fan controller of the config mode: curve or pid
+func createFanController(cfg fanConfig) (fan.Controller, error) {
+	switch cfg.Mode {
+	case "curve":
+		curve, err := fan.ParseCurve(cfg.Curve)
+		if err != nil {
+			return nil, err
+		}
+
+		controller := &fan.CurveController{
+			Curve:      curve,
+			Hysteresis: cfg.Hysteresis,
+			MinDuty:    cfg.MinPercent / 100,
+			SpinUp:     cfg.SpinUp.Duration,
+		}
+
+		return controller, controller.Validate()
+	case "pid":
+		controller := fan.NewPIDController(cfg.Target)
+		controller.Kp = cfg.Kp
+		controller.Ki = cfg.Ki
+		controller.Kd = cfg.Kd
+		controller.MinDuty = cfg.MinPercent / 100
+
+		return controller, controller.Validate()
+	}
+
+	return nil, fmt.Errorf("unknown fan mode \"%s\", use one of: curve, pid", cfg.Mode)
+}
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return fmt.Errorf("duration must be a string like \"2s\": %v", err)
+	}
+
+	d.Duration, err = time.ParseDuration(s)
+
+	return err
+}
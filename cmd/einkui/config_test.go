@@ -0,0 +1,186 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes the json config to a temporary file removed when the test ends
+func writeConfig(t *testing.T, config string) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "einkui")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+
+	path := filepath.Join(dir, "config.json")
+
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestDecodeAppConfigRejectsUnknownKeys(t *testing.T) {
+	for _, config := range []string{
+		`{"panle": "2in13v2"}`,
+		`{"fan": {"speed": 50}}`,
+		`{"pages": [{"name": "Disk", "type": "disks", "disk": ["sda"]}]}`,
+		`{"menu": {"items": [{"label": "Exit", "action": "exit", "key": "ok"}]}}`,
+	} {
+		if _, err := decodeAppConfig([]byte(config)); err == nil || !strings.Contains(err.Error(), "unknown field") {
+			t.Errorf("%s: got %v, want an unknown field error", config, err)
+		}
+	}
+}
+
+func TestDecodeAppConfigDurations(t *testing.T) {
+	cfg, err := decodeAppConfig([]byte(`{"fan": {"interval": "5s", "spinup": "1500ms"}, "menu": {"confirm_timeout": "0s"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Fan.Interval.Duration != 5*time.Second {
+		t.Errorf("interval %s, want 5s", cfg.Fan.Interval.Duration)
+	}
+
+	if cfg.Fan.SpinUp.Duration != 1500*time.Millisecond {
+		t.Errorf("spinup %s, want 1.5s", cfg.Fan.SpinUp.Duration)
+	}
+
+	if cfg.Menu.ConfirmTimeout.Duration != 0 {
+		t.Errorf("confirm_timeout %s, want 0", cfg.Menu.ConfirmTimeout.Duration)
+	}
+
+	for _, config := range []string{
+		`{"fan": {"interval": 5}}`,
+		`{"fan": {"interval": "fast"}}`,
+		`{"menu": {"confirm_timeout": "10"}}`,
+	} {
+		if _, err := decodeAppConfig([]byte(config)); err == nil {
+			t.Errorf("%s: no error", config)
+		}
+	}
+}
+
+func TestDecodeAppConfigKeepsDefaults(t *testing.T) {
+	cfg, err := decodeAppConfig([]byte(`{"index_page": "Load", "fan": {"mode": "pid"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := defaultAppConfig()
+
+	if cfg.Fan.Mode != "pid" || cfg.Fan.Curve != defaults.Fan.Curve || cfg.Fan.Interval != defaults.Fan.Interval {
+		t.Errorf("fan %+v, want the defaults with the pid mode", cfg.Fan)
+	}
+
+	if len(cfg.Pages) != len(defaults.Pages) || len(cfg.Menu.Items) != len(defaults.Menu.Items) {
+		t.Errorf("the default pages and menu items were not kept: %+v %+v", cfg.Pages, cfg.Menu.Items)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() appConfig {
+		cfg := defaultAppConfig()
+		cfg.replaceDiskPages(diskPages([]string{"sda"}, true))
+
+		return cfg
+	}
+
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("the default config with a disk: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(cfg *appConfig)
+		want   string
+	}{
+		{"no disks", func(cfg *appConfig) { cfg.Pages = defaultAppConfig().Pages }, "no partition"},
+		{"unknown panel", func(cfg *appConfig) { cfg.Panel = "7in5" }, "7in5"},
+		{"duplicate page", func(cfg *appConfig) { cfg.Pages = append(cfg.Pages, cfg.Pages[0]) }, "duplicate page name"},
+		{"unknown index page", func(cfg *appConfig) { cfg.IndexPage = "Nope" }, "index_page"},
+		{"negative interval", func(cfg *appConfig) { cfg.Pages[0].RefreshInterval = -1 }, "pages[0]: refresh_interval"},
+		{"unknown action", func(cfg *appConfig) { cfg.Menu.Items = []menuItemConfig{{Label: "Jump", Action: "jump"}} }, "items[0]: unknown action"},
+		{"unknown setting", func(cfg *appConfig) {
+			cfg.Menu.Items = []menuItemConfig{{Label: "Settings", Items: []menuItemConfig{{Label: "X", Setting: "x"}}}}
+		}, "items[0]: items[0]: unknown setting"},
+		{"negative confirm timeout", func(cfg *appConfig) { cfg.Menu.ConfirmTimeout.Duration = -time.Second }, "confirm_timeout"},
+		{"zero fan interval", func(cfg *appConfig) { cfg.Fan.Interval.Duration = 0 }, "interval must be positive"},
+		{"disk full", func(cfg *appConfig) { cfg.Led.DiskFull = 101 }, "disk_full"},
+	}
+
+	for _, test := range tests {
+		cfg := valid()
+		test.change(&cfg)
+
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got %v, want an error with %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestLoadConfigFlagsOverride(t *testing.T) {
+	path := writeConfig(t, `{
+  "index_page": "Mine",
+  "pages": [
+    {"type": "load", "name": "Load"},
+    {"type": "disks", "name": "Mine", "disks": ["sdb"]}
+  ],
+  "fan": {"mode": "pid", "target": 50, "interval": "3s"},
+  "led": {"disk_full": 80}
+}`)
+
+	cfg, _, err := loadConfig([]string{"-config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.IndexPage != "Mine" || cfg.Fan.Mode != "pid" || cfg.Fan.Target != 50 || cfg.Led.DiskFull != 80 {
+		t.Errorf("config not loaded: %+v", cfg)
+	}
+
+	cfg, _, err = loadConfig([]string{"-config", path, "-d", "sda", "-index", "Load", "-fan-target", "60", "-nl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.IndexPage != "Load" {
+		t.Errorf("index page %q, want the one of the flag", cfg.IndexPage)
+	}
+
+	if cfg.Fan.Target != 60 || cfg.Fan.Mode != "pid" || cfg.Fan.Interval.Duration != 3*time.Second {
+		t.Errorf("fan %+v, want the target of the flag over the config", cfg.Fan)
+	}
+
+	if cfg.Led.Enabled || cfg.Led.DiskFull != 80 {
+		t.Errorf("led %+v, want disabled by the flag with the config disk_full", cfg.Led)
+	}
+
+	if len(cfg.Pages) != 2 || cfg.Pages[0].Name != "Disk 1" || cfg.Pages[0].Disks[0] != "sda" || cfg.Pages[1].Name != "Load" {
+		t.Errorf("pages %+v, want the disks of the flag in place of the config ones", cfg.Pages)
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	path := writeConfig(t, `{"pages": [{"type": "disks", "name": "Disk", "disks": ["sda"]}], "index_page": "Nope"}`)
+
+	if _, _, err := loadConfig([]string{"-config", path}); err == nil {
+		t.Error("no error for an unknown index page")
+	}
+
+	if _, _, err := loadConfig([]string{"-config", path, "-index", "Disk"}); err != nil {
+		t.Errorf("the flag did not override the invalid index page: %v", err)
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -8,18 +10,27 @@ import (
 	"github.com/shirou/gopsutil/cpu"
 	"github.com/shirou/gopsutil/disk"
 	"github.com/shirou/gopsutil/mem"
+	"periph.io/x/periph/conn/spi"
 	"image"
+	"image/color"
 	"log"
 	"nas-kit-ui/pkg/epd"
+	"nas-kit-ui/pkg/fan"
+	"nas-kit-ui/pkg/led"
 	"nas-kit-ui/pkg/nasui"
 	"net"
 	"os"
 	"os/exec"
+	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
+const simSnapshotPath = "./sim.png"
+
 type arrayFlags []string
 
 func (af *arrayFlags) String() string {
@@ -31,312 +42,635 @@ func (af *arrayFlags) Set(value string) error {
 	return nil
 }
 
+// cliFlags are the flags which are not a part of the config file
+type cliFlags struct {
+	configPath    string
+	disks         arrayFlags
+	notGroup      bool
+	noFan         bool
+	noLed         bool
+	ledRules      arrayFlags
+	debug         bool
+	sim           bool
+	pins          arrayFlags
+	binds         arrayFlags
+	spiMode       int
+	accent        float64
+	dither        string
+	threshold     uint
+	refreshPolicy nasui.RefreshPolicy
+	buttons       epd.ButtonConfig
+	epd           epd.Config
+}
+
 func main()  {
-	var diskFlags arrayFlags
-	var notGroupFlag bool
-	var noFanFlag bool
-	var debugFlag bool
+	cfg, flags, err := loadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("Creating UI")
 
-	flag.Var(&diskFlags, "d", "Partition label(s) to estimate the size")
-	flag.BoolVar(&debugFlag, "p", false, "Debug UI and dump page to file")
-	flag.BoolVar(&notGroupFlag, "ng", false, "Not group partitions")
-	flag.BoolVar(&noFanFlag, "nf", false, "Do not use the FAN")
-	flag.Parse()
+	var paper *epd.Epaper
 
-	if len(diskFlags) == 0 {
-		log.Fatal(errors.New("no partition(s) specified"))
+	epdConfig := flags.epd
+	epdConfig.Panel = cfg.Panel
+
+	if flags.sim {
+		panel, err := epd.LookupPanel(epdConfig.Panel)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		paper = createSimulatedEpd(panel, simSnapshotPath)
+	} else {
+		epdConfig.SPIMode = spi.Mode(flags.spiMode)
+
+		err := applyPinFlags(&epdConfig.Pins, flags.pins)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		paper, err = epd.New(epdConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	fmt.Println("Creating UI")
+	conversionMode, err := epd.ParseConversionMode(flags.dither)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	ui := createUi(debugFlag, noFanFlag)
+	if flags.threshold < 1 || flags.threshold > 255 {
+		log.Fatal(errors.New("threshold must be in 1-255 range"))
+	}
+
+	paper.SetConversion(epd.Conversion{Mode: conversionMode, Level: uint8(flags.threshold)})
 
-	twoPathsCnt := len(diskFlags) / 2
+	s := &settings{cfg: cfg, configPath: flags.configPath}
 
-	diskCounter := 0
-	if !notGroupFlag && twoPathsCnt > 0 {
-		diskFlags = addTwoPathsPages(diskFlags, &diskCounter, twoPathsCnt, ui)
+	if cfg.StateFile != "" {
+		s.state = &stateFile{path: cfg.StateFile}
 	}
 
-	if len(diskFlags) > 0 {
-		addSinglePathPages(&diskCounter, diskFlags, ui)
+	ui, err := createUi(paper, cfg, s, flags.debug)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	addLoadPage(ui)
+	ui.RefreshPolicy = &flags.refreshPolicy
+	ui.Buttons = &flags.buttons
 
-	err := ui.Run()
+	if s.state != nil {
+		ui.StateStore = s.state
+	}
 
+	ui.Bindings, err = parseBindFlags(flags.binds)
 	if err != nil {
-		log.Println(err)
+		log.Fatal(err)
 	}
-}
 
-func gracefullShutdown(epaper *epd.Epaper)  {
-	epaper.StopFan()
+	if paper.Panel().Red {
+		ui.DefaultUI.AccentColor = color.RGBA{R: 0xff, A: 0xff}
+		ui.DefaultUI.AccentThreshold = flags.accent
+	}
+
+	if flags.configPath != "" {
+		reloadOnHangup(ui, s, os.Args[1:])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	err := epaper.InitFull()
+	cancelOnSignal(cancel)
+
+	err = ui.Run(ctx)
 
 	if err != nil {
 		log.Fatal(err)
 	}
+}
+
+// cancelOnSignal cancels the ui on the interrupt or terminate signal, the ui puts the panel to sleep then
+func cancelOnSignal(cancel context.CancelFunc) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-c
+		cancel()
+	}()
+}
+
+// loadConfig reads the config file given by the args and applies the other flags over it, the settings saved
+// in the state file are read instead when there is no config file. The refresh intervals of the pages
+// missing in the config are read from the state file.
+func loadConfig(args []string) (appConfig, *cliFlags, error) {
+	cfg := defaultAppConfig()
+
+	flags, err := parseFlags(&cfg, args)
+	if err != nil {
+		return cfg, nil, err
+	}
+
+	reparse := false
+
+	if flags.configPath != "" {
+		cfg, err = loadAppConfig(flags.configPath)
+		if err != nil {
+			return cfg, nil, err
+		}
+
+		reparse = true
+	} else if cfg.StateFile != "" {
+		statePath := cfg.StateFile
+
+		data, err := (&stateFile{path: statePath}).settings()
+		if err == nil && data != nil {
+			cfg, err = decodeAppConfig(data)
+			cfg.StateFile = statePath
+
+			if err != nil {
+				err = fmt.Errorf("state %s: settings: %v", statePath, err)
+			}
+		}
+
+		if err != nil {
+			// the broken settings are replaced by the next saved ones
+			log.Printf("settings not restored: %v", err)
+		}
+
+		reparse = true
+	}
+
+	if reparse {
+		// the flags are parsed again over the config, so only the given ones override it
+		flags, err = parseFlags(&cfg, args)
+		if err != nil {
+			return cfg, nil, err
+		}
+	}
+
+	flags.apply(&cfg)
+
+	if cfg.StateFile != "" {
+		intervals, err := (&stateFile{path: cfg.StateFile}).refreshIntervals()
+		if err != nil {
+			log.Printf("refresh intervals not restored: %v", err)
+		}
+
+		cfg.setRefreshIntervals(intervals)
+	}
+
+	return cfg, flags, cfg.Validate()
+}
+
+// reloadOnHangup reloads the config on SIGHUP and rebuilds the pages and the menu of the running ui,
+// the ui keeps the previous ones if the config is invalid
+func reloadOnHangup(ui *nasui.NasUI, s *settings, args []string) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+
+	go func() {
+		for range c {
+			configPath, err := reloadConfig(ui, s, args)
+
+			if err != nil {
+				log.Printf("config not reloaded, the previous one is kept: %v", err)
+				continue
+			}
+
+			log.Printf("config %s reloaded", configPath)
+		}
+	}()
+}
+
+// reloadConfig loads the config of the args and gives its pages and menu to the ui, nothing is changed
+// if the config is invalid. It returns the path of the reloaded config file.
+func reloadConfig(ui *nasui.NasUI, s *settings, args []string) (string, error) {
+	cfg, flags, err := loadConfig(args)
+	if err != nil {
+		return "", err
+	}
+
+	err = ui.Reload(createPages(cfg), createMenu(cfg, s), cfg.IndexPage)
+	if err != nil {
+		return "", err
+	}
+
+	s.reload(cfg)
+
+	return flags.configPath, nil
+}
+
+// parseFlags parses the args into a new cliFlags and the cfg, the cfg values are the defaults of its flags
+func parseFlags(cfg *appConfig, args []string) (*cliFlags, error) {
+	flags := &cliFlags{
+		refreshPolicy: nasui.DefaultRefreshPolicy,
+		buttons:       epd.DefaultButtonConfig,
+		epd:           epd.DefaultConfig(),
+	}
+
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+
+	fs.StringVar(&flags.configPath, "config", "", "Path to the json config file, the other flags override it")
+	fs.Var(&flags.disks, "d", "Partition label(s) to estimate the size")
+	fs.BoolVar(&flags.debug, "p", false, "Debug UI and dump page to file")
+	fs.BoolVar(&flags.notGroup, "ng", false, "Not group partitions")
+	fs.StringVar(&cfg.IndexPage, "index", cfg.IndexPage, "Name of the page shown first")
+	fs.StringVar(&cfg.StateFile, "state", cfg.StateFile, "File keeping the shown page and the settings across the restarts")
+	fs.BoolVar(&flags.noFan, "nf", false, "Do not use the FAN")
+	fs.BoolVar(&flags.noLed, "nl", false, "Do not use the led")
+	fs.Float64Var(&cfg.Led.DiskFull, "disk-full", cfg.Led.DiskFull, "Disk usage percent from which the led shows the full disk")
+	fs.Var(&flags.ledRules, "led-rule", "Led pattern of a state in state=pattern form e.g. healthy=breathe, states: healthy, disk-full, driver-error, patterns: off, on, slow, fast, heartbeat, breathe")
+	fs.StringVar(&cfg.Fan.Mode, "fan-mode", cfg.Fan.Mode, "Fan control: curve (speed by temperature) or pid (keeps the -fan-target temperature)")
+	fs.StringVar(&cfg.Fan.Curve, "fan-curve", cfg.Fan.Curve, "Fan speed by CPU temperature as comma separated temp:percent points")
+	fs.Float64Var(&cfg.Fan.Hysteresis, "fan-hysteresis", cfg.Fan.Hysteresis, "Temperature drop in °C before the fan slows down")
+	fs.Float64Var(&cfg.Fan.MinPercent, "fan-min", cfg.Fan.MinPercent, "Lowest fan speed percent the fan keeps spinning at")
+	fs.DurationVar(&cfg.Fan.SpinUp.Duration, "fan-spinup", cfg.Fan.SpinUp.Duration, "How long a stopped fan is started at full speed")
+	fs.Float64Var(&cfg.Fan.Target, "fan-target", cfg.Fan.Target, "CPU temperature in °C the pid fan control keeps")
+	fs.Float64Var(&cfg.Fan.Kp, "fan-kp", cfg.Fan.Kp, "Proportional gain of the pid fan control")
+	fs.Float64Var(&cfg.Fan.Ki, "fan-ki", cfg.Fan.Ki, "Integral gain of the pid fan control")
+	fs.Float64Var(&cfg.Fan.Kd, "fan-kd", cfg.Fan.Kd, "Derivative gain of the pid fan control")
+	fs.Var(&flags.epd.FanPWMFrequency, "fan-pwm-freq", "Fan hardware PWM frequency e.g. 25kHz")
+	fs.BoolVar(&flags.sim, "s", false, "Run with a simulated e-paper device, controlled from stdin")
+	fs.Float64Var(&flags.accent, "accent", 90, "Disk usage percent from which the gauge is drawn red on three-color panels, 0 disables")
+	fs.StringVar(&flags.dither, "dither", "threshold", "Conversion of the pages to black and white: threshold, ordered or fs (Floyd–Steinberg)")
+	fs.UintVar(&flags.threshold, "threshold", 128, "Luminance 1-255 from which a pixel is white in the threshold conversion")
+	fs.IntVar(&flags.refreshPolicy.MaxPartialUpdates, "full-every", flags.refreshPolicy.MaxPartialUpdates, "Force a full refresh after this many partial ones to clear the ghosting, 0 disables")
+	fs.DurationVar(&flags.refreshPolicy.MaxPartialAge, "full-after", flags.refreshPolicy.MaxPartialAge, "Force a full refresh when the last one is older than this, 0 disables")
+	fs.DurationVar(&flags.buttons.LongPress, "long-press", flags.buttons.LongPress, "How long a button is held for a long press, 0 disables long presses")
+	fs.DurationVar(&flags.buttons.RepeatEvery, "repeat-every", flags.buttons.RepeatEvery, "Repeat interval of a button held after the long press, 0 disables repeats")
+	fs.Var(&flags.binds, "bind", fmt.Sprintf("Button binding in trigger=action form e.g. long:ok=refresh or add+sub=led, actions: %s", strings.Join(nasui.ActionNames(), ", ")))
+	fs.Var(&flags.pins, "pin", "Gpio pin override in role=NAME form e.g. fan=GPIO12, roles: rst, dc, cs, busy, ok, back, add, sub, fan, led")
+	fs.StringVar(&cfg.Panel, "panel", cfg.Panel, fmt.Sprintf("E-paper panel, one of: %s", strings.Join(epd.PanelNames(), ", ")))
+	fs.StringVar(&flags.epd.SPIDevice, "spi", "", "SPI device name e.g. SPI0.0, the first available one if empty")
+	fs.Var(&flags.epd.SPIClock, "spi-clock", "SPI clock frequency e.g. 2MHz")
+	fs.IntVar(&flags.spiMode, "spi-mode", int(flags.epd.SPIMode), "SPI mode 0-3")
+	fs.DurationVar(&flags.epd.BusyTimeout, "busy-timeout", flags.epd.BusyTimeout, "Longest wait for the panel to get ready before it is reported as failed, 0 waits forever")
+
+	return flags, fs.Parse(args)
+}
+
+// apply applies the flags which change the cfg in other way than setting its value
+func (f *cliFlags) apply(cfg *appConfig) {
+	if len(f.disks) > 0 {
+		cfg.replaceDiskPages(diskPages(f.disks, !f.notGroup))
+	}
+
+	if f.noFan {
+		cfg.Fan.Enabled = false
+	}
+
+	if f.noLed {
+		cfg.Led.Enabled = false
+	}
+
+	// the rules of the flags win over the ones of the config
+	cfg.Led.Rules = append(append([]string{}, f.ledRules...), cfg.Led.Rules...)
+}
+
+func applyPinFlags(pins *epd.PinMap, pinFlags arrayFlags) error {
+	for _, pinFlag := range pinFlags {
+		parts := strings.SplitN(pinFlag, "=", 2)
+
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid pin flag \"%s\", expected role=NAME", pinFlag)
+		}
+
+		err := pins.Set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseBindFlags returns the default bindings with the ones of the flags applied
+func parseBindFlags(bindFlags arrayFlags) ([]nasui.Binding, error) {
+	var overrides []nasui.Binding
+
+	for _, bindFlag := range bindFlags {
+		binding, err := nasui.ParseBinding(bindFlag)
+		if err != nil {
+			return nil, err
+		}
+
+		overrides = append(overrides, binding)
+	}
+
+	return nasui.MergeBindings(nasui.DefaultBindings, overrides...), nil
+}
+
+// reportDiskUsage sets the disk full led state of the disk
+func reportDiskUsage(ctx *nasui.Context, di *nasui.DiskInfo, diskFull float64) {
+	if ctx.NasUI.Led != nil {
+		ctx.NasUI.Led.SetState(led.StateDiskFull+"/"+di.Path, di.UsedPercent >= diskFull)
+	}
+}
+
+// createSimulatedEpd creates a simulated e-paper which saves every refresh to the snapshotPath
+// and presses the buttons read from stdin: "o" - ok, "b" - back, "a" - add, "s" - sub,
+// a duration after the button holds it, e.g. "o 2s"
+func createSimulatedEpd(panel epd.Panel, snapshotPath string) *epd.Epaper {
+	sim := epd.NewSimulator(panel)
+	sim.SnapshotPath = snapshotPath
+
+	go func() {
+		buttons := map[string]int{
+			"o": epd.BtnOk,
+			"b": epd.BtnBack,
+			"a": epd.BtnAdd,
+			"s": epd.BtnSub,
+		}
+
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 0 {
+				continue
+			}
+
+			btn, ok := buttons[fields[0]]
+
+			if !ok {
+				log.Println("unknown button, use one of: o, b, a, s")
+				continue
+			}
+
+			if len(fields) == 1 {
+				sim.Press(btn)
+				continue
+			}
+
+			hold, err := time.ParseDuration(fields[1])
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+
+			sim.Hold(btn, hold)
+		}
+	}()
+
+	return epd.NewSimulated(sim)
+}
+
+// menuActions create the commands of the menu items by the action name of the config
+var menuActions = map[string]func() *nasui.Command{
+	"reboot":   rebootCommand,
+	"poweroff": powerOffCommand,
+	"uptime":   uptimeCommand,
+	"clear":    clearScreenCommand,
+	"exit":     exitCommand,
+}
 
-	epaper.Sleep()
+// confirmActions are the menu actions asking the question before they run
+var confirmActions = map[string]string{
+	"reboot":   "Reboot now?",
+	"poweroff": "Power off now?",
 }
 
-func createUi(debugMode bool, noFan bool) *nasui.NasUI {
+// createUi creates the ui with the pages and the menu of the cfg, the settings of the menu change the ui
+func createUi(paper *epd.Epaper, cfg appConfig, s *settings, debugMode bool) (*nasui.NasUI, error) {
 	ui := &nasui.NasUI{
 		Debug: debugMode,
-		DefaultUI: nasui.NewDefaultUI(nasui.OrientationVertical, "JetBrainsMono-Regular.ttf"),
-		Epd: epd.New(true),
+		DefaultUI: nasui.NewDefaultUI(paper.Panel(), nasui.OrientationVertical, cfg.Font),
+		Epd: paper,
+		IndexPageName: cfg.IndexPage,
 		Orientation: nasui.OrientationVertical,
-		Menu:       &nasui.Menu{
-			Label:     "Menu",
-			Page: &nasui.Page{
-				Name:            "Menu page",
-				RefreshInterval: 2,
-				Display: func(ctx *nasui.Context) (*image.RGBA, error) {
-					return ctx.DefaultUI.MenuPage(ctx)
-				},
-			},
-			MenuItems: []nasui.MenuItem{
-				{
-					Label: "Reboot Device",
-					Page: &nasui.Page{
-						RefreshInterval: 2,
-						Display: func(ctx *nasui.Context) (*image.RGBA, error) {
-							currentPage := ctx.NasUI.GetCurrentPage()
-
-							if currentPage == nil {
-								return nil, errors.New("no current page is set")
-							}
-
-							if !currentPage.IsFirstTimeDisplay() {
-								gracefullShutdown(ctx.NasUI.Epd)
-
-								cmd := exec.Command("bash", "-c", "sudo reboot")
-								err := cmd.Run()
-
-								return nil, err
-							}
-
-							return ctx.DefaultUI.MenuActionTextPage("Menu: reboot", []string{"Rebooting ... "}), nil
-						},
-					},
-				},
-				{
-					Label: "Power off",
-					Page: &nasui.Page{
-						RefreshInterval: 2,
-						Display: func(ctx *nasui.Context) (*image.RGBA, error) {
-							currentPage := ctx.NasUI.GetCurrentPage()
-
-							if currentPage == nil {
-								return nil, errors.New("no current page is set!")
-							}
-
-							if !currentPage.IsFirstTimeDisplay() {
-								gracefullShutdown(ctx.NasUI.Epd)
-
-								cmd := exec.Command("bash", "-c", "sudo poweroff")
-								err := cmd.Run()
-
-								return nil, err
-							}
-							return ctx.DefaultUI.MenuActionTextPage("Menu: power off", []string{"Power off ... "}), nil
-						},
-					},
-				},
-				{
-					Label: "Uptime",
-					Page: &nasui.Page{
-						RefreshInterval: 0.5,
-						Display: func(ctx *nasui.Context) (*image.RGBA, error) {
-							cmd := exec.Command("bash", "-c", "uptime -p")
-							out, err := cmd.Output()
-
-							if err != nil {
-								return nil, err
-							}
-
-							uptimeStrs := strings.Split(string(out), ",")
-
-							return ctx.DefaultUI.MenuActionTextPage("Menu: uptime", uptimeStrs), nil
-						},
-					},
-				},
-				{
-					Label: "Clear screen",
-					Page: &nasui.Page{
-						RefreshInterval: 0,
-						Display: func(ctx *nasui.Context) (*image.RGBA, error) {
-							err := ctx.NasUI.Epd.InitFull()
-							if err != nil {
-								return nil, err
-							}
-
-							for i:=0;i < 3;i++ {
-								ctx.NasUI.Epd.Reset()
-								ctx.NasUI.Epd.Clear(epd.BgColorWhite)
-								time.Sleep(4 * time.Second)
-								ctx.NasUI.Epd.Clear(epd.BgColorWhite)
-							}
-
-							return ctx.DefaultUI.MenuActionTextPage("Menu: clear screen", []string{"cleared"}), nil
-						},
-					},
-				},
-				{
-					Label: "Exit",
-					Page: &nasui.Page{
-						RefreshInterval: 0.5,
-						Display: func(ctx *nasui.Context) (*image.RGBA, error) {
-							currentPage := ctx.NasUI.GetCurrentPage()
-
-							if currentPage == nil {
-								return nil, errors.New("no current page set")
-							}
-
-							if !currentPage.IsFirstTimeDisplay() {
-								gracefullShutdown(ctx.NasUI.Epd)
-
-								os.Exit(0)
-
-								return nil, nil
-							}
-
-							return ctx.DefaultUI.MenuActionTextPage("Menu: exit", []string{"Bye!"}), nil
-						},
-					},
-				},
+		Menu: createMenu(cfg, s),
+	}
+
+	s.ui = ui
+
+	if len(cfg.FinalText) > 0 {
+		ui.FinalPage = &nasui.Page{
+			Name: "Final page",
+			Display: func(ctx *nasui.Context) (*image.RGBA, error) {
+				return ctx.DefaultUI.MenuActionTextPage(cfg.FinalTitle, cfg.FinalText), nil
 			},
-		},
+		}
 	}
 
-	if !noFan {
+	ui.AddPages(createPages(cfg)...)
+
+	if cfg.Led.Enabled {
+		rules, err := cfg.Led.rules()
+		if err != nil {
+			return nil, err
+		}
+
+		ui.Led = led.NewController(led.OutputFunc(paper.SetLedDuty), rules)
+	}
+
+	if cfg.Fan.Enabled {
+		fanController, err := createFanController(cfg.Fan)
+		if err != nil {
+			return nil, err
+		}
+
+		s.fan = fan.NewSwitch(fanController)
+
 		ui.BackgroundProc = func(ctx *nasui.Context) error {
-			for {
-				temp, err := getCpuTemp()
+			return fan.Run(
+				ctx,
+				fan.TempSourceFunc(getCpuTemp),
+				fan.OutputFunc(ctx.NasUI.Epd.SetFanDuty),
+				s.fan,
+				cfg.Fan.Interval.Duration)
+		}
+	}
 
-				if err != nil {
-					return err
-				}
+	return ui, nil
+}
 
-				if temp >= 55 {
-					ctx.NasUI.Epd.StartFan()
-				}
+// createMenu creates the menu with the items and the submenus of the cfg
+func createMenu(cfg appConfig, s *settings) *nasui.Menu {
+	menu := nasui.NewMenu(cfg.Menu.Label, &nasui.Page{
+		Name:            "Menu page",
+		RefreshInterval: 2,
+		Display: func(ctx *nasui.Context) (*image.RGBA, error) {
+			return ctx.DefaultUI.MenuPage(ctx)
+		},
+	}, createMenuItems(cfg, cfg.Menu.Items, s)...)
+	menu.PerPage = cfg.Menu.PerPage
 
-				if temp <= 43 {
-					ctx.NasUI.Epd.StopFan()
-				}
+	return menu
+}
+
+// createMenuItems creates the items and the submenus of the cfg, the confirmActions ask before they run
+func createMenuItems(cfg appConfig, items []menuItemConfig, s *settings) []nasui.MenuItem {
+	var menuItems []nasui.MenuItem
 
-				time.Sleep(2 * time.Second)
+	for _, item := range items {
+		if len(item.Items) > 0 {
+			menuItems = append(menuItems, nasui.SubmenuItem(item.Label, createMenuItems(cfg, item.Items, s)...))
+			continue
+		}
+
+		if item.Setting != "" {
+			menuItems = append(menuItems, settingItems[item.Setting](s, cfg, item.Label))
+			continue
+		}
+
+		menuItem := nasui.CommandItem(item.Label, menuActions[item.Action]())
+
+		if question, ok := confirmActions[item.Action]; ok {
+			menuItem.Confirm = &nasui.Confirm{
+				Lines:   []string{question},
+				Timeout: cfg.Menu.ConfirmTimeout.Duration,
 			}
 		}
+
+		menuItems = append(menuItems, menuItem)
 	}
 
-	return ui
+	return menuItems
 }
 
-func addTwoPathsPages(diskFlags arrayFlags, discCounter *int, twoPathsCnt int, ui *nasui.NasUI) arrayFlags  {
-	for i := 0; i < twoPathsCnt; i++ {
-		diskPaths := []string{diskFlags[i], diskFlags[i+1]}
+// createPages creates the pages of the cfg in its order
+func createPages(cfg appConfig) []*nasui.Page {
+	var pages []*nasui.Page
 
-		label := fmt.Sprintf("Disk %d&%d", *discCounter+1, *discCounter+2)
-		*discCounter += 2
+	for _, page := range cfg.Pages {
+		switch page.Type {
+		case pageTypeDisks:
+			pages = append(pages, disksPage(page, cfg.Led.DiskFull))
+		case pageTypeLoad:
+			pages = append(pages, loadPage(page))
+		}
+	}
 
-		ui.AddPages(&nasui.Page{
-			Name:            label,
-			RefreshInterval: 0.8,
-			Display: func(ctx *nasui.Context) (*image.RGBA, error) {
-				partitionStat, err := getPartitionStat(diskPaths)
+	return pages
+}
 
-				if err != nil {
-					return nil, err
-				}
+func menuActionNames() []string {
+	var names []string
 
-				if len(partitionStat) < 2 {
-					log.Fatal(fmt.Sprintf("Partitions \"%s\" not found or stat not avaliable", diskPaths))
-				}
+	for name := range menuActions {
+		names = append(names, name)
+	}
 
-				diskStats := []*nasui.DiskInfo{
-					{
-						Idx: 		 fmt.Sprintf("%d", i),
-						Path:        partitionStat[0].Path,
-						Total:       humanize.Bytes(partitionStat[0].Total),
-						Free:        humanize.Bytes(partitionStat[0].Free),
-						Used:        humanize.Bytes(partitionStat[0].Used),
-						UsedPercent: partitionStat[0].UsedPercent,
-					},
-					{
-						Idx: 		 fmt.Sprintf("%d", i+1),
-						Path:        partitionStat[1].Path,
-						Total:       humanize.Bytes(partitionStat[1].Total),
-						Free:        humanize.Bytes(partitionStat[1].Free),
-						Used:        humanize.Bytes(partitionStat[1].Used),
-						UsedPercent: partitionStat[1].UsedPercent,
-					},
-				}
+	sort.Strings(names)
 
-				img, err := ctx.DefaultUI.DiscInfoTwoDiscs(label, getOutboundIP().String(), diskStats)
+	return names
+}
 
-				if err != nil {
-					return nil, err
-				}
+func rebootCommand() *nasui.Command {
+	return &nasui.Command{
+		Title:   "Menu: reboot",
+		Working: []string{"Rebooting ... "},
+		Run: func(ctx *nasui.Context) nasui.CommandResult {
+			// the system stops the ui with SIGTERM, it puts the panel to sleep then
+			err := exec.CommandContext(ctx, "bash", "-c", "sudo reboot").Run()
 
-				return img, nil
-			},
-		})
+			return nasui.CommandResult{Text: []string{"Rebooting ... "}, Err: err}
+		},
+	}
+}
 
-		diskFlags = remove(diskFlags, i+1)
-		diskFlags = remove(diskFlags, i)
+func powerOffCommand() *nasui.Command {
+	return &nasui.Command{
+		Title:   "Menu: power off",
+		Working: []string{"Power off ... "},
+		Run: func(ctx *nasui.Context) nasui.CommandResult {
+			err := exec.CommandContext(ctx, "bash", "-c", "sudo poweroff").Run()
+
+			return nasui.CommandResult{Text: []string{"Power off ... "}, Err: err}
+		},
 	}
+}
 
-	return diskFlags
+func uptimeCommand() *nasui.Command {
+	return &nasui.Command{
+		Title:   "Menu: uptime",
+		Timeout: 5 * time.Second,
+		Run: func(ctx *nasui.Context) nasui.CommandResult {
+			out, err := exec.CommandContext(ctx, "bash", "-c", "uptime -p").Output()
+
+			if err != nil {
+				return nasui.CommandResult{Err: err}
+			}
+
+			return nasui.CommandResult{Text: strings.Split(string(out), ",")}
+		},
+	}
 }
 
-func addSinglePathPages(diskCounter *int, diskFlags arrayFlags, ui *nasui.NasUI)  {
-	for _, diskFlag := range diskFlags {
-		*diskCounter++
-		label := fmt.Sprintf("Disk %d", *diskCounter)
-		ui.AddPages(&nasui.Page{
-			Name:            label,
-			RefreshInterval: 0.8,
-			Display: func(ctx *nasui.Context) (*image.RGBA, error) {
-				partitionStat, err := getPartitionStat([]string{diskFlag})
+// clearScreenCommand shows its result with a full refresh, it clears the ghosting of the panel
+func clearScreenCommand() *nasui.Command {
+	return &nasui.Command{
+		Title: "Menu: clear screen",
+		Run: func(ctx *nasui.Context) nasui.CommandResult {
+			return nasui.CommandResult{Text: []string{"cleared"}, FullRefresh: true}
+		},
+	}
+}
 
-				if err != nil {
-					return nil, err
-				}
+func exitCommand() *nasui.Command {
+	return &nasui.Command{
+		Title: "Menu: exit",
+		Run: func(ctx *nasui.Context) nasui.CommandResult {
+			ctx.NasUI.Stop()
 
-				if len(partitionStat) != 1 {
-					log.Fatal(fmt.Sprintf("Partition \"%s\" not found or stat not avaliable", diskFlag))
-				}
+			return nasui.CommandResult{Text: []string{"Bye!"}}
+		},
+	}
+}
+
+// disksPage creates the page of one or two disks, the led shows the disks used over the diskFull percent
+func disksPage(page pageConfig, diskFull float64) *nasui.Page {
+	label := page.label()
+	disks := page.Disks
+
+	return &nasui.Page{
+		Name:            page.Name,
+		RefreshInterval: page.RefreshInterval,
+		Display: func(ctx *nasui.Context) (*image.RGBA, error) {
+			partitionStat, err := getPartitionStat(disks)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if len(partitionStat) != len(disks) {
+				return nil, fmt.Errorf("partition(s) \"%s\" not found or stat not avaliable", strings.Join(disks, "\", \""))
+			}
+
+			var diskStats []*nasui.DiskInfo
 
-				img, err := ctx.DefaultUI.DiscInfoOneDisc(
-					label,
-					getOutboundIP().String(),
-					&nasui.DiskInfo{
-						Path:        partitionStat[0].Path,
-						Total:       humanize.Bytes(partitionStat[0].Total),
-						Free:        humanize.Bytes(partitionStat[0].Free),
-						Used:        humanize.Bytes(partitionStat[0].Used),
-						UsedPercent: partitionStat[0].UsedPercent,
-					})
-
-				if err != nil {
-					return nil, err
+			for idx, stat := range partitionStat {
+				diskStat := &nasui.DiskInfo{
+					Idx:         fmt.Sprintf("%d", idx+1),
+					Path:        stat.Path,
+					Total:       humanize.Bytes(stat.Total),
+					Free:        humanize.Bytes(stat.Free),
+					Used:        humanize.Bytes(stat.Used),
+					UsedPercent: stat.UsedPercent,
 				}
 
-				return img, nil
-			},
-		})
+				reportDiskUsage(ctx, diskStat, diskFull)
+				diskStats = append(diskStats, diskStat)
+			}
+
+			if len(diskStats) == 1 {
+				return ctx.DefaultUI.DiscInfoOneDisc(label, getOutboundIP().String(), diskStats[0])
+			}
+
+			return ctx.DefaultUI.DiscInfoTwoDiscs(label, getOutboundIP().String(), diskStats)
+		},
 	}
 }
 
-func addLoadPage(ui *nasui.NasUI) {
-	ui.AddPages(&nasui.Page{
-		Name:            "Load",
-		RefreshInterval: 0.5,
+func loadPage(page pageConfig) *nasui.Page {
+	label := page.label()
+
+	return &nasui.Page{
+		Name:            page.Name,
+		RefreshInterval: page.RefreshInterval,
 		Display: func(ctx *nasui.Context) (*image.RGBA, error) {
 			cpuPercent, err := cpu.Percent(0, false)
 			if err != nil {
@@ -361,13 +695,9 @@ func addLoadPage(ui *nasui.NasUI) {
 				RamUsed:    humanize.Bytes(memInfo.Used),
 			}
 
-			return ctx.DefaultUI.ResourcesInfo("Usage", getOutboundIP().String(), usageInfo)
+			return ctx.DefaultUI.ResourcesInfo(label, getOutboundIP().String(), usageInfo)
 		},
-	})
-}
-
-func remove(diskFlags arrayFlags, s int) arrayFlags {
-	return append(diskFlags[:s], diskFlags[s+1:]...)
+	}
 }
 
 func getPartitionStat(paths []string) ([]*disk.UsageStat, error) {
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"nas-kit-ui/pkg/epd"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// reloadTestConfig has the disk pages of the names and the led and the fan off, so it runs on any machine
+func reloadTestConfig(index string, names ...string) string {
+	config := `{"index_page": "` + index + `", "fan": {"enabled": false}, "led": {"enabled": false}, "pages": [`
+
+	for i, name := range names {
+		if i > 0 {
+			config += ", "
+		}
+
+		config += `{"type": "disks", "name": "` + name + `", "disks": ["sda"]}`
+	}
+
+	return config + "]}"
+}
+
+// waitFor polls the cond until it holds, the test fails after five seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	path := writeConfig(t, reloadTestConfig("One", "One", "Two"))
+	args := []string{"-config", path}
+
+	cfg, _, err := loadConfig(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	panel, err := epd.LookupPanel(cfg.Panel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sim := epd.NewSimulator(panel)
+	s := &settings{cfg: cfg, configPath: path}
+
+	ui, err := createUi(epd.NewSimulated(sim), cfg, s, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() {
+		done <- ui.Run(ctx)
+	}()
+
+	defer func() {
+		cancel()
+
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	current := func(name string) func() bool {
+		return func() bool {
+			page := ui.GetCurrentPage()
+			return page != nil && page.Name == name
+		}
+	}
+
+	waitFor(t, "the index page", current("One"))
+	sim.Press(epd.BtnSub)
+	waitFor(t, "the second page", current("Two"))
+
+	shown := ui.GetCurrentPage()
+
+	// an unknown index page makes the config invalid
+	if err := ioutil.WriteFile(path, []byte(reloadTestConfig("Nope", "Two", "Three")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := reloadConfig(ui, s, args); err == nil {
+		t.Fatal("the invalid config was reloaded")
+	}
+
+	if names := ui.PageNames(); len(names) != 2 || names[0] != "One" || names[1] != "Two" {
+		t.Errorf("pages %v after the invalid config, want the previous ones", names)
+	}
+
+	if s.cfg.IndexPage != "One" {
+		t.Errorf("settings index page %q after the invalid config, want the previous one", s.cfg.IndexPage)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(reloadTestConfig("Three", "Three", "Two")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reloadOnHangup(ui, s, args)
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "the reloaded pages", func() bool {
+		return ui.Page("Three") != nil
+	})
+
+	waitFor(t, "the reloaded shown page", func() bool {
+		page := ui.GetCurrentPage()
+		return page != shown && page == ui.Page("Two")
+	})
+
+	if s.cfg.IndexPage != "Three" {
+		t.Errorf("settings index page %q, want the reloaded one", s.cfg.IndexPage)
+	}
+}
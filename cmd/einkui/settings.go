@@ -0,0 +1,351 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"nas-kit-ui/pkg/fan"
+	"nas-kit-ui/pkg/nasui"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// settings are the config values edited from the menu, they are applied to the running ui and saved to the
+// config file. The flags still override the saved values on the next start. The ui gets the values of the
+// editors holding its lock, so the ui is not called while s.mu is held.
+type settings struct {
+	mu  sync.Mutex
+	cfg appConfig
+	// configPath is the file the settings are saved to, they are saved to the state if empty
+	configPath string
+	// state keeps the settings when there is no config file, they are kept until the restart if nil too
+	state *stateFile
+	ui    *nasui.NasUI
+	// fan takes the controller of the changed fan settings, nil if the fan is not used
+	fan *fan.Switch
+}
+
+// settingItems create the menu items editing the settings by the setting name of the config, the cfg is
+// the one the menu is created from
+var settingItems = map[string]func(s *settings, cfg appConfig, label string) nasui.MenuItem{
+	"index_page":       indexPageItem,
+	"refresh_interval": refreshIntervalItem,
+	"fan_mode":         fanModeItem,
+	"fan_target":       fanTargetItem,
+	"led":              ledItem,
+}
+
+func settingNames() []string {
+	var names []string
+
+	for name := range settingItems {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// reload takes the reloaded config, the fan and the led ones are only read on start so they are kept
+func (s *settings) reload(cfg appConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg.Fan, cfg.Led = s.cfg.Fan, s.cfg.Led
+	s.cfg = cfg
+}
+
+func indexPageItem(s *settings, cfg appConfig, label string) nasui.MenuItem {
+	var names []string
+
+	for _, page := range cfg.Pages {
+		names = append(names, page.Name)
+	}
+
+	return nasui.EditorItem(label, &nasui.ChoiceEditor{
+		Choices: names,
+		Get: func() string {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			return s.cfg.IndexPage
+		},
+		Set: func(name string) error {
+			err := s.ui.SetIndexPage(name)
+			if err != nil {
+				return err
+			}
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			s.cfg.IndexPage = name
+
+			return s.save(func(file map[string]interface{}) {
+				file["index_page"] = name
+			})
+		},
+	})
+}
+
+// refreshIntervalItem is a submenu editing the refresh interval of every page, 0 draws the page only once
+func refreshIntervalItem(s *settings, cfg appConfig, label string) nasui.MenuItem {
+	var items []nasui.MenuItem
+
+	for _, page := range cfg.Pages {
+		name := page.Name
+
+		items = append(items, nasui.EditorItem(page.label(), &nasui.NumberEditor{
+			Min:    0,
+			Max:    60,
+			Step:   0.5,
+			Format: "%gs",
+			Get: func() float64 {
+				s.mu.Lock()
+				defer s.mu.Unlock()
+
+				if idx := s.pageIndex(name); idx != -1 {
+					return s.cfg.Pages[idx].RefreshInterval
+				}
+
+				return 0
+			},
+			Set: func(interval float64) error {
+				return s.setRefreshInterval(name, interval)
+			},
+		}))
+	}
+
+	return nasui.SubmenuItem(label, items...)
+}
+
+func (s *settings) setRefreshInterval(name string, interval float64) error {
+	page := s.ui.Page(name)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := s.pageIndex(name)
+
+	if idx == -1 || page == nil {
+		return fmt.Errorf("no page named \"%s\"", name)
+	}
+
+	page.SetRefreshInterval(interval)
+
+	// the pages are copied as the menus are created from the previous ones
+	pages := append([]pageConfig{}, s.cfg.Pages...)
+	pages[idx].RefreshInterval = interval
+	s.cfg.Pages = pages
+
+	inConfig := false
+
+	err := s.save(func(file map[string]interface{}) {
+		pages, _ := file["pages"].([]interface{})
+
+		for _, p := range pages {
+			if p, ok := p.(map[string]interface{}); ok && p["name"] == name {
+				p["refresh_interval"] = interval
+				inConfig = true
+
+				return
+			}
+		}
+	})
+
+	if err != nil || s.state == nil {
+		return err
+	}
+
+	if inConfig {
+		return s.state.clearRefreshInterval(name)
+	}
+
+	// the page is a default one or one of the -d flags, it is not added to the config with the other ones
+	return s.state.saveRefreshInterval(name, interval)
+}
+
+func fanModeItem(s *settings, cfg appConfig, label string) nasui.MenuItem {
+	return nasui.EditorItem(label, &nasui.ChoiceEditor{
+		Choices: []string{"curve", "pid"},
+		Get: func() string {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			return s.cfg.Fan.Mode
+		},
+		Set: func(mode string) error {
+			return s.setFan(func(cfg *fanConfig) {
+				cfg.Mode = mode
+			}, "mode", mode)
+		},
+	})
+}
+
+func fanTargetItem(s *settings, cfg appConfig, label string) nasui.MenuItem {
+	return nasui.EditorItem(label, &nasui.NumberEditor{
+		Min:    30,
+		Max:    80,
+		Step:   1,
+		Format: "%.0f°C",
+		Get: func() float64 {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			return s.cfg.Fan.Target
+		},
+		Set: func(target float64) error {
+			return s.setFan(func(cfg *fanConfig) {
+				cfg.Target = target
+			}, "target", target)
+		},
+	})
+}
+
+// setFan changes the fan config and saves the value at the key of the fan config, the running fan takes
+// the new controller at once
+func (s *settings) setFan(change func(cfg *fanConfig), key string, value interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg := s.cfg.Fan
+	change(&cfg)
+
+	controller, err := createFanController(cfg)
+	if err != nil {
+		return err
+	}
+
+	if s.fan != nil {
+		s.fan.Set(controller)
+	}
+
+	s.cfg.Fan = cfg
+
+	return s.save(func(file map[string]interface{}) {
+		configSection(file, "fan")[key] = value
+	})
+}
+
+// errLedNotUsed fails the led setting when the ui drives no led, nothing would change
+var errLedNotUsed = errors.New("the led is not used")
+
+// ledItem turns the led on and off, it is applied at once
+func ledItem(s *settings, cfg appConfig, label string) nasui.MenuItem {
+	return nasui.EditorItem(label, &nasui.ToggleEditor{
+		Get: func() bool {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			return s.cfg.Led.Enabled
+		},
+		Set: func(enabled bool) error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			if s.ui.Led == nil {
+				return errLedNotUsed
+			}
+
+			s.ui.Led.SetEnabled(enabled)
+
+			s.cfg.Led.Enabled = enabled
+
+			return s.save(func(file map[string]interface{}) {
+				configSection(file, "led")["enabled"] = enabled
+			})
+		},
+	})
+}
+
+// save changes the config file by the edit, the other values of the file stay as they are, s.mu is held.
+// The settings of the state are changed when there is no config file.
+func (s *settings) save(edit func(file map[string]interface{})) error {
+	if s.configPath == "" {
+		if s.state != nil {
+			return s.state.saveSettings(edit)
+		}
+
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(s.configPath)
+	if err != nil {
+		return err
+	}
+
+	file := map[string]interface{}{}
+
+	err = json.Unmarshal(data, &file)
+	if err != nil {
+		return fmt.Errorf("config %s: %v", s.configPath, err)
+	}
+
+	edit(file)
+
+	data, err = json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.configPath, append(data, '\n'))
+}
+
+func (s *settings) pageIndex(name string) int {
+	for idx, page := range s.cfg.Pages {
+		if page.Name == name {
+			return idx
+		}
+	}
+
+	return -1
+}
+
+// configSection returns the object of the key in the config file, it is added if missing
+func configSection(file map[string]interface{}, key string) map[string]interface{} {
+	section, ok := file[key].(map[string]interface{})
+	if !ok {
+		section = map[string]interface{}{}
+		file[key] = section
+	}
+
+	return section
+}
+
+// writeFileAtomic replaces the file with the data at once, a crash leaves either the old or the new file
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode()
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".einkui-*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+
+	if err == nil {
+		err = os.Chmod(tmp.Name(), mode)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
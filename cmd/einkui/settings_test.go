@@ -0,0 +1,87 @@
+package main
+
+import (
+	"io/ioutil"
+	"nas-kit-ui/pkg/nasui"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetRefreshIntervalOfPageMissingInConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "einkui")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	configPath := filepath.Join(dir, "config.json")
+	config := "{\n  \"pages\": [\n    {\n      \"name\": \"Mine\"\n    }\n  ]\n}\n"
+
+	if err := ioutil.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ui := &nasui.NasUI{}
+	ui.AddPages(&nasui.Page{Name: "Mine"}, &nasui.Page{Name: "Disk sda"})
+
+	s := &settings{
+		cfg:        appConfig{Pages: []pageConfig{{Name: "Mine"}, {Name: "Disk sda"}}},
+		configPath: configPath,
+		state:      &stateFile{path: filepath.Join(dir, "state.json")},
+		ui:         ui,
+	}
+
+	if err := s.setRefreshInterval("Disk sda", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := ioutil.ReadFile(configPath); string(data) != config {
+		t.Errorf("the config file was changed for a page missing in it:\n%s", data)
+	}
+
+	intervals, err := s.state.refreshIntervals()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if intervals["Disk sda"] != 2 {
+		t.Errorf("saved intervals %v, want 2 for Disk sda", intervals)
+	}
+
+	if err := s.setRefreshInterval("Mine", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadAppConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Pages[0].RefreshInterval != 3 {
+		t.Errorf("config interval %g, want 3", cfg.Pages[0].RefreshInterval)
+	}
+
+	cfg.setRefreshIntervals(intervals)
+
+	if len(cfg.Pages) != 1 {
+		t.Errorf("the saved intervals added pages: %v", cfg.Pages)
+	}
+}
+
+func TestLedSettingWithoutLed(t *testing.T) {
+	s := &settings{ui: &nasui.NasUI{}}
+
+	editor := ledItem(s, appConfig{}, "Led").Editor
+	editor.Begin()
+	editor.Change(1)
+
+	if err := editor.Commit()(); err != errLedNotUsed {
+		t.Errorf("got %v, want %v", err, errLedNotUsed)
+	}
+
+	if s.cfg.Led.Enabled {
+		t.Error("the led setting was changed with no led")
+	}
+}
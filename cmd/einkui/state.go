@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"nas-kit-ui/pkg/nasui"
+	"os"
+	"sync"
+)
+
+// stateFile keeps the ui state, the refresh intervals of the pages missing in the config and, when there is
+// no config file, the settings edited on the device
+// in a json file, the file is replaced at once on every save
+type stateFile struct {
+	mu   sync.Mutex
+	path string
+}
+
+// stateContent is the json of the stateFile
+type stateContent struct {
+	UI nasui.State `json:"ui"`
+	// Settings are in the form of the config file, they are read over the defaults as the config file
+	Settings map[string]interface{} `json:"settings,omitempty"`
+	// RefreshIntervals are the edited intervals of the pages missing in the config file and the settings
+	// e.g. the default pages or the ones of the -d flags, by the page name
+	RefreshIntervals map[string]float64 `json:"refresh_intervals,omitempty"`
+}
+
+// Load returns the saved ui state, the zero one if the file does not exist yet
+func (f *stateFile) Load() (nasui.State, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	content, err := f.read()
+
+	return content.UI, err
+}
+
+// Save saves the ui state keeping the settings, a broken file is replaced as it is not restored anyway
+func (f *stateFile) Save(state nasui.State) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	content, _ := f.read()
+	content.UI = state
+
+	return f.write(content)
+}
+
+// saveSettings changes the settings by the edit keeping the ui state, a broken file is replaced
+func (f *stateFile) saveSettings(edit func(settings map[string]interface{})) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	content, _ := f.read()
+
+	if content.Settings == nil {
+		content.Settings = map[string]interface{}{}
+	}
+
+	edit(content.Settings)
+
+	return f.write(content)
+}
+
+// settings returns the json of the saved settings, nil if there are none
+func (f *stateFile) settings() ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	content, err := f.read()
+	if err != nil || content.Settings == nil {
+		return nil, err
+	}
+
+	return json.Marshal(content.Settings)
+}
+
+// saveRefreshInterval saves the interval of the page keeping the ui state and the settings
+func (f *stateFile) saveRefreshInterval(name string, interval float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	content, _ := f.read()
+
+	if content.RefreshIntervals == nil {
+		content.RefreshIntervals = map[string]float64{}
+	}
+
+	content.RefreshIntervals[name] = interval
+
+	return f.write(content)
+}
+
+// clearRefreshInterval removes the saved interval of the page, the config keeps it then
+func (f *stateFile) clearRefreshInterval(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	content, err := f.read()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := content.RefreshIntervals[name]; !ok {
+		return nil
+	}
+
+	delete(content.RefreshIntervals, name)
+
+	return f.write(content)
+}
+
+// refreshIntervals returns the saved intervals of the pages by the page name
+func (f *stateFile) refreshIntervals() (map[string]float64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	content, err := f.read()
+
+	return content.RefreshIntervals, err
+}
+
+func (f *stateFile) read() (stateContent, error) {
+	var content stateContent
+
+	data, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return content, nil
+	}
+
+	if err != nil {
+		return content, err
+	}
+
+	err = json.Unmarshal(data, &content)
+	if err != nil {
+		return stateContent{}, fmt.Errorf("state %s: %v", f.path, err)
+	}
+
+	return content, nil
+}
+
+func (f *stateFile) write(content stateContent) error {
+	data, err := json.MarshalIndent(content, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(f.path, append(data, '\n'))
+}
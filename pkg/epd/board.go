@@ -1,102 +1,80 @@
 package epd
 
 import (
-	"log"
 	"time"
-
-	"periph.io/x/periph/conn/gpio"
-	"periph.io/x/periph/conn/gpio/gpioreg"
-	"periph.io/x/periph/conn/physic"
-	"periph.io/x/periph/conn/spi"
-	"periph.io/x/periph/conn/spi/spireg"
-	"periph.io/x/periph/host"
-)
-
-const (
-	// default gpio pins
-	defaultPinRST  = "GPIO17"
-	defaultPinDC   = "GPIO25"
-	defaultPinCS   = "GPIO8"
-	defaultPinBUSY = "GPIO24"
-	defaultPinKeyOk = "GPIO5"
-	defaultPinKeyBack = "GPIO6"
-	defaultPinKeyAdd = "GPIO13"
-	defaultPinKeySub = "GPIO19"
-	defaultPinFan = "GPIO18"
-	defaultPinLed = "GPIO26"
 )
 
-type board struct {
-	connected bool
-	port    spi.PortCloser
-	spiConn spi.Conn
-	// gpio pins
-	pinRST  gpio.PinOut
-	pinDC   gpio.PinOut
-	pinCS   gpio.PinOut
-	pinBUSY gpio.PinIn
-
-	// button pins
-	pinKeyOk   gpio.PinIn
-	pinKeyBack gpio.PinIn
-	pinKeyAdd    gpio.PinIn
-	pinKeySub   gpio.PinIn
+// Transport sends commands and data to the panel controller
+type Transport interface {
+	// Command sends a command byte
+	Command(cmd byte) error
+	// Data sends data bytes of the last command
+	Data(data ...byte) error
+	// WaitBusy blocks while the BUSY pin reads the level, controllers differ in the busy level.
+	// ErrBusyTimeout is returned if the panel stays busy for too long.
+	WaitBusy(level bool) error
+	// Reset does the hardware reset of the controller
+	Reset() error
+	Close() error
+}
 
-	// led and fan
-	pinFan gpio.PinOut
-	pinLed gpio.PinOut
+// OutputPin is a digital output pin
+type OutputPin interface {
+	Out(high bool)
 }
 
-func (p *board) init() error {
-	_, err := host.Init()
-	if err != nil {
-		return err
-	}
+// InputPin is a digital input pin
+type InputPin interface {
+	Read() bool
+	// WaitForEdge waits for the level change, negative timeout means wait forever
+	WaitForEdge(timeout time.Duration) bool
+}
 
-	p.port, err = spireg.Open("")
-	if err != nil {
-		return err
-	}
+// Hardware is everything the e-paper HAT is wired to
+type Hardware struct {
+	Transport Transport
 
-	p.spiConn, err = p.port.Connect(2*physic.MegaHertz, spi.Mode0, 8)
-	if err != nil {
-		return err
-	}
+	// buttons
+	KeyOk   InputPin
+	KeyBack InputPin
+	KeyAdd  InputPin
+	KeySub  InputPin
 
-	p.pinRST = gpioreg.ByName(defaultPinRST)
-	p.pinDC = gpioreg.ByName(defaultPinDC)
-	p.pinCS = gpioreg.ByName(defaultPinCS)
-	p.pinBUSY = gpioreg.ByName(defaultPinBUSY)
+	// led and fan, they are driven with software PWM unless they implement DutyPin
+	Fan OutputPin
+	Led OutputPin
+}
 
-	p.pinKeyOk = gpioreg.ByName(defaultPinKeyOk)
-	err = p.pinKeyOk.In(gpio.PullDown, gpio.BothEdges)
-	if err != nil {
-		return err
-	}
+// Provider opens the board hardware
+type Provider interface {
+	Open() (*Hardware, error)
+}
 
-	p.pinKeyBack = gpioreg.ByName(defaultPinKeyBack)
-	err = p.pinKeyBack.In(gpio.PullDown, gpio.BothEdges)
-	if err != nil {
-		return err
-	}
+type board struct {
+	provider  Provider
+	connected bool
+	hw        *Hardware
+	fan       DutyPin
+	fanDuty   float64
+	led       DutyPin
+}
 
-	p.pinKeyAdd = gpioreg.ByName(defaultPinKeyAdd)
-	err = p.pinKeyAdd.In(gpio.PullDown, gpio.BothEdges)
-	if err != nil {
-		return err
-	}
+func newBoard(provider Provider) *board {
+	return &board{provider: provider}
+}
 
-	p.pinKeySub = gpioreg.ByName(defaultPinKeySub)
-	err = p.pinKeySub.In(gpio.PullDown, gpio.BothEdges)
+func (p *board) init() error {
+	hw, err := p.provider.Open()
 	if err != nil {
 		return err
 	}
 
-	p.pinLed = gpioreg.ByName(defaultPinLed)
-	p.pinFan = gpioreg.ByName(defaultPinFan)
-
+	p.hw = hw
 	p.connected = true
 
+	p.fan = dutyPin(hw.Fan)
+	p.led = dutyPin(hw.Led)
+
 	return nil
 }
 
@@ -104,114 +82,69 @@ func (p *board) isConnected() bool  {
 	return p.connected
 }
 
-func (p *board) keyOkPressed() bool  {
-	p.pinKeyOk.WaitForEdge(-1)
-
-	return p.pinKeyOk.Read() == gpio.Low
-}
-
-func (p *board) keyBackPressed() bool  {
-	p.pinKeyBack.WaitForEdge(-1)
-
-	return p.pinKeyBack.Read() == gpio.Low
-}
-
-func (p *board) keyAddPressed() bool  {
-	p.pinKeyAdd.WaitForEdge(-1)
-
-	return p.pinKeyAdd.Read() == gpio.Low
-}
-
-func (p *board) keySubPressed() bool  {
-	p.pinKeySub.WaitForEdge(-1)
-
-	return p.pinKeySub.Read() == gpio.Low
-}
-
-func (p *board) ledOn()  {
-	p.writeLED(true)
+func (p *board) transport() Transport {
+	return p.hw.Transport
 }
 
-func (p *board) ledOff()  {
-	p.writeLED(false)
-}
-
-func (p *board) writeLED(v bool)  {
-	if v {
-		p.pinLed.Out(gpio.High)
-	} else {
-		p.pinLed.Out(gpio.Low)
+// key returns the pin of the button, btn is one of the Btn* constants
+func (p *board) key(btn int) InputPin {
+	switch btn {
+	case BtnOk:
+		return p.hw.KeyOk
+	case BtnBack:
+		return p.hw.KeyBack
+	case BtnAdd:
+		return p.hw.KeyAdd
+	case BtnSub:
+		return p.hw.KeySub
 	}
-}
 
-func (p *board) fanOn()  {
-	p.writeFAN(true)
+	return nil
 }
 
-func (p *board) fanOff()  {
-	p.writeFAN(false)
+func (p *board) ledOn() error {
+	return p.led.SetDuty(1)
 }
 
-func (p *board) writeFAN(v bool)  {
-	if v {
-		p.pinFan.Out(gpio.High)
-	} else {
-		p.pinFan.Out(gpio.Low)
-	}
+func (p *board) ledOff() error {
+	return p.led.SetDuty(0)
 }
 
-func (p *board) writeRST(v bool) {
-	if v {
-		p.pinRST.Out(gpio.High)
-	} else {
-		p.pinRST.Out(gpio.Low)
-	}
+func (p *board) fanOn() error {
+	return p.setFanDuty(1)
 }
 
-func (p *board) writeDC(v bool) {
-	if v {
-		p.pinDC.Out(gpio.High)
-	} else {
-		p.pinDC.Out(gpio.Low)
-	}
+func (p *board) fanOff() error {
+	return p.setFanDuty(0)
 }
 
-func (p *board) writeCS(v bool) {
-	if v {
-		p.pinCS.Out(gpio.High)
-	} else {
-		p.pinCS.Out(gpio.Low)
+func (p *board) setFanDuty(duty float64) error {
+	err := p.fan.SetDuty(duty)
+	if err != nil {
+		return err
 	}
-}
 
-func (p *board) readBUSY() {
-	for p.pinBUSY.Read() == gpio.High {
-		p.delayms(100)
-	}
-}
+	p.fanDuty = duty
 
-func (p *board) writeSPI(b ...byte) {
-	r := make([]byte, len(b))
-	p.spiConn.Tx(b, r)
+	return nil
 }
 
-func (p *board) cleanup() {
+func (p *board) cleanup() error {
 	defer func() {
 		p.connected = false
 	}()
 
-	p.fanOff()
-	p.ledOff()
-	p.writeRST(false)
-	p.writeDC(false)
+	// the transport is closed even if the fan fails
+	_ = p.fanOff()
+	_ = p.ledOff()
 
-	err := p.port.Close()
+	return p.hw.Transport.Close()
+}
 
-	if err != nil {
-		log.Println(err)
+func dutyPin(pin OutputPin) DutyPin {
+	if dp, ok := pin.(DutyPin); ok {
+		return dp
 	}
-}
 
-func (p *board) delayms(ms uint) {
-	time.Sleep(time.Millisecond * time.Duration(ms))
+	return NewSoftPWM(pin, softPWMFrequency)
 }
@@ -0,0 +1,183 @@
+package epd
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// ButtonDown is sent when the button is pressed down
+	ButtonDown = iota
+	// ButtonUp is sent when the button is released
+	ButtonUp
+	// ButtonShortPress is sent after ButtonUp when the button was released before the long press
+	ButtonShortPress
+	// ButtonLongPress is sent once the button is held for ButtonConfig.LongPress
+	ButtonLongPress
+	// ButtonRepeat is sent every ButtonConfig.RepeatEvery while the button is held after the long press
+	ButtonRepeat
+)
+
+// buttonPoll is how often the button watchers check whether they are cancelled
+const buttonPoll = 100 * time.Millisecond
+
+// ButtonEvent is a debounced event of a button
+type ButtonEvent struct {
+	// Button is one of the Btn* constants
+	Button int
+	// Kind is one of the ButtonDown, ButtonUp, ButtonShortPress, ButtonLongPress, ButtonRepeat
+	Kind int
+	At   time.Time
+}
+
+// ButtonConfig is the timing of the button events
+type ButtonConfig struct {
+	// Debounce is the time for the contacts to settle after an edge before the level is read
+	Debounce time.Duration
+	// LongPress is how long a button is held to send ButtonLongPress, 0 disables long presses and repeats
+	LongPress time.Duration
+	// RepeatEvery is the interval of ButtonRepeat after the long press, 0 disables repeats
+	RepeatEvery time.Duration
+}
+
+// DefaultButtonConfig suits the NAS-Kit HAT buttons
+var DefaultButtonConfig = ButtonConfig{
+	Debounce:    20 * time.Millisecond,
+	LongPress:   800 * time.Millisecond,
+	RepeatEvery: 250 * time.Millisecond,
+}
+
+// Buttons watches all the buttons and sends their events to the returned channel until the ctx is done,
+// the channel is closed after that. The board must be initialized with InitBoard first.
+func (p *Epaper) Buttons(ctx context.Context, cfg ButtonConfig) (<-chan ButtonEvent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.board.isConnected() {
+		return nil, ErrNotConnected
+	}
+
+	events := make(chan ButtonEvent)
+	wg := &sync.WaitGroup{}
+
+	for _, btn := range []int{BtnOk, BtnBack, BtnAdd, BtnSub} {
+		wg.Add(1)
+
+		go func(btn int, pin InputPin) {
+			defer wg.Done()
+			watchButton(ctx, btn, pin, cfg, events)
+		}(btn, p.board.key(btn))
+	}
+
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+
+	return events, nil
+}
+
+// ReadButtons sends the short pressed buttons to the btnChan
+//
+// Deprecated: use Buttons which also reports long presses and stops with its context
+func (p *Epaper) ReadButtons(btnChan chan int) {
+	events, err := p.Buttons(context.Background(), DefaultButtonConfig)
+	if err != nil {
+		return
+	}
+
+	go func() {
+		for event := range events {
+			if event.Kind == ButtonShortPress {
+				btnChan <- event.Button
+			}
+		}
+	}()
+}
+
+// watchButton turns the edges of the pin into button events, the buttons are pulled up so low is pressed
+func watchButton(ctx context.Context, btn int, pin InputPin, cfg ButtonConfig, events chan<- ButtonEvent) {
+	pressed := false
+	long := false
+	// next is the time of the next long press or repeat event while the button is held
+	var next time.Time
+
+	send := func(kind int) bool {
+		select {
+		case events <- ButtonEvent{Button: btn, Kind: kind, At: time.Now()}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for ctx.Err() == nil {
+		timeout := buttonPoll
+		if pressed && !next.IsZero() {
+			if until := time.Until(next); until < timeout {
+				timeout = until
+			}
+
+			if timeout < 0 {
+				timeout = 0
+			}
+		}
+
+		if !pin.WaitForEdge(timeout) {
+			if !pressed || next.IsZero() || time.Now().Before(next) {
+				continue
+			}
+
+			kind := ButtonRepeat
+			if !long {
+				kind = ButtonLongPress
+				long = true
+			}
+
+			next = time.Time{}
+			if cfg.RepeatEvery > 0 {
+				next = time.Now().Add(cfg.RepeatEvery)
+			}
+
+			if !send(kind) {
+				return
+			}
+
+			continue
+		}
+
+		// the edges of the bouncing contacts are skipped by reading the level once it is settled
+		time.Sleep(cfg.Debounce)
+
+		down := !pin.Read()
+		if down == pressed {
+			continue
+		}
+
+		pressed = down
+
+		if pressed {
+			long = false
+			next = time.Time{}
+
+			if cfg.LongPress > 0 {
+				next = time.Now().Add(cfg.LongPress)
+			}
+
+			if !send(ButtonDown) {
+				return
+			}
+
+			continue
+		}
+
+		if !send(ButtonUp) {
+			return
+		}
+
+		if !long && !send(ButtonShortPress) {
+			return
+		}
+	}
+}
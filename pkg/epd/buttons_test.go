@@ -0,0 +1,161 @@
+package epd
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// watchFakeButton watches a released fake button with the cfg until the test ends
+func watchFakeButton(t *testing.T, cfg ButtonConfig) (*FakePin, <-chan ButtonEvent) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pin := NewFakePin(true)
+	events := make(chan ButtonEvent)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		watchButton(ctx, BtnOk, pin, cfg, events)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	return pin, events
+}
+
+// nextEvents returns the events sent until none comes for the quiet time
+func nextEvents(events <-chan ButtonEvent, quiet time.Duration) []ButtonEvent {
+	var got []ButtonEvent
+
+	for {
+		select {
+		case event := <-events:
+			got = append(got, event)
+		case <-time.After(quiet):
+			return got
+		}
+	}
+}
+
+func assertKinds(t *testing.T, got []ButtonEvent, want ...int) {
+	t.Helper()
+
+	kinds := make([]int, len(got))
+	for i, event := range got {
+		kinds[i] = event.Kind
+	}
+
+	if len(kinds) != len(want) {
+		t.Fatalf("events %v, want %v", kinds, want)
+	}
+
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("events %v, want %v", kinds, want)
+		}
+	}
+}
+
+func TestButtonBounceIsRejected(t *testing.T) {
+	pin, events := watchFakeButton(t, ButtonConfig{Debounce: time.Millisecond})
+
+	// the bouncing contacts settle on the same level again
+	pin.Set(false)
+	pin.Set(false)
+	pin.Set(true)
+	pin.Set(true)
+
+	assertKinds(t, nextEvents(events, 50*time.Millisecond), ButtonDown, ButtonUp, ButtonShortPress)
+}
+
+func TestButtonLongPressThreshold(t *testing.T) {
+	const longPress = 60 * time.Millisecond
+
+	pin, events := watchFakeButton(t, ButtonConfig{Debounce: time.Millisecond, LongPress: longPress})
+
+	pin.Set(false)
+	time.Sleep(longPress / 3)
+	pin.Set(true)
+
+	assertKinds(t, nextEvents(events, longPress*2), ButtonDown, ButtonUp, ButtonShortPress)
+
+	pin.Set(false)
+	got := nextEvents(events, longPress*2)
+	pin.Set(true)
+	got = append(got, nextEvents(events, 50*time.Millisecond)...)
+
+	assertKinds(t, got, ButtonDown, ButtonLongPress, ButtonUp)
+
+	if held := got[1].At.Sub(got[0].At); held < longPress {
+		t.Errorf("long press after %s, want %s", held, longPress)
+	}
+}
+
+func TestButtonRepeatSpacing(t *testing.T) {
+	const (
+		longPress   = 30 * time.Millisecond
+		repeatEvery = 20 * time.Millisecond
+	)
+
+	pin, events := watchFakeButton(t, ButtonConfig{Debounce: time.Millisecond, LongPress: longPress, RepeatEvery: repeatEvery})
+
+	pin.Set(false)
+
+	var got []ButtonEvent
+
+	for len(got) < 5 {
+		select {
+		case event := <-events:
+			got = append(got, event)
+		case <-time.After(time.Second):
+			t.Fatalf("got %d events, want 5", len(got))
+		}
+	}
+
+	pin.Set(true)
+
+	assertKinds(t, got, ButtonDown, ButtonLongPress, ButtonRepeat, ButtonRepeat, ButtonRepeat)
+
+	for i := 2; i < len(got); i++ {
+		if spacing := got[i].At.Sub(got[i-1].At); spacing < repeatEvery {
+			t.Errorf("repeat %d after %s, want %s", i-1, spacing, repeatEvery)
+		}
+	}
+}
+
+func TestButtonZeroDisables(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  ButtonConfig
+		want []int
+	}{
+		{
+			name: "long press",
+			cfg:  ButtonConfig{Debounce: time.Millisecond, RepeatEvery: 10 * time.Millisecond},
+			want: []int{ButtonDown, ButtonUp, ButtonShortPress},
+		},
+		{
+			name: "repeat",
+			cfg:  ButtonConfig{Debounce: time.Millisecond, LongPress: 10 * time.Millisecond},
+			want: []int{ButtonDown, ButtonLongPress, ButtonUp},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pin, events := watchFakeButton(t, test.cfg)
+
+			pin.Set(false)
+			got := nextEvents(events, 80*time.Millisecond)
+			pin.Set(true)
+			got = append(got, nextEvents(events, 50*time.Millisecond)...)
+
+			assertKinds(t, got, test.want...)
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package epd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/spi"
+)
+
+// PinMap names the gpio pins the HAT is wired to, names are the ones known to gpioreg e.g. "GPIO17"
+type PinMap struct {
+	RST     string
+	DC      string
+	CS      string
+	BUSY    string
+	KeyOk   string
+	KeyBack string
+	KeyAdd  string
+	KeySub  string
+	Fan     string
+	Led     string
+}
+
+// Config is the e-paper hardware configuration
+type Config struct {
+	// Panel is the name of the registered panel, see PanelNames
+	Panel string
+	Pins  PinMap
+	// SPIDevice is the spireg port name e.g. "SPI0.0", empty means the first available port
+	SPIDevice string
+	SPIClock  physic.Frequency
+	SPIMode   spi.Mode
+	// BusyTimeout is the longest time to wait for the panel to get ready, 0 means wait forever
+	BusyTimeout time.Duration
+	// FanPWMFrequency is the frequency of the fan hardware PWM, pins without it use a slow software PWM
+	FanPWMFrequency physic.Frequency
+}
+
+type pinRole struct {
+	role string
+	name *string
+}
+
+// PinNotFoundError is returned when a configured pin does not exist in gpioreg
+type PinNotFoundError struct {
+	Role string
+	Name string
+}
+
+var (
+	ErrInvalidSPIClock        = errors.New("spi clock must be positive")
+	ErrInvalidSPIMode         = errors.New("spi mode must be one of 0, 1, 2, 3")
+	ErrInvalidBusyTimeout     = errors.New("busy timeout must not be negative")
+	ErrInvalidFanPWMFrequency = errors.New("fan pwm frequency must be positive")
+)
+
+// DefaultConfig returns the configuration of the Sunfounder NAS-Kit HAT on a Raspberry Pi
+func DefaultConfig() Config {
+	return Config{
+		Panel: defaultPanel,
+		Pins: PinMap{
+			RST:     defaultPinRST,
+			DC:      defaultPinDC,
+			CS:      defaultPinCS,
+			BUSY:    defaultPinBUSY,
+			KeyOk:   defaultPinKeyOk,
+			KeyBack: defaultPinKeyBack,
+			KeyAdd:  defaultPinKeyAdd,
+			KeySub:  defaultPinKeySub,
+			Fan:     defaultPinFan,
+			Led:     defaultPinLed,
+		},
+		SPIClock:        2 * physic.MegaHertz,
+		SPIMode:         spi.Mode0,
+		BusyTimeout:     10 * time.Second,
+		FanPWMFrequency: 25 * physic.KiloHertz,
+	}
+}
+
+// Validate checks the configuration without touching the hardware
+func (c Config) Validate() error {
+	if _, err := LookupPanel(c.Panel); err != nil {
+		return err
+	}
+
+	if c.SPIClock <= 0 {
+		return ErrInvalidSPIClock
+	}
+
+	if c.SPIMode < spi.Mode0 || c.SPIMode > spi.Mode3 {
+		return ErrInvalidSPIMode
+	}
+
+	if c.FanPWMFrequency <= 0 {
+		return ErrInvalidFanPWMFrequency
+	}
+
+	if c.BusyTimeout < 0 {
+		return ErrInvalidBusyTimeout
+	}
+
+	used := map[string]string{}
+
+	for _, pin := range c.Pins.roles() {
+		if *pin.name == "" {
+			return fmt.Errorf("gpio pin for %s is not set", pin.role)
+		}
+
+		if role, ok := used[*pin.name]; ok {
+			return fmt.Errorf("gpio pin %s is used for both %s and %s", *pin.name, role, pin.role)
+		}
+
+		used[*pin.name] = pin.role
+	}
+
+	return nil
+}
+
+// Set sets the pin of the role, role is one of rst, dc, cs, busy, ok, back, add, sub, fan, led
+func (m *PinMap) Set(role string, name string) error {
+	for _, pin := range m.roles() {
+		if pin.role == role {
+			*pin.name = name
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown pin role %q", role)
+}
+
+func (m *PinMap) roles() []pinRole {
+	return []pinRole{
+		{"rst", &m.RST},
+		{"dc", &m.DC},
+		{"cs", &m.CS},
+		{"busy", &m.BUSY},
+		{"ok", &m.KeyOk},
+		{"back", &m.KeyBack},
+		{"add", &m.KeyAdd},
+		{"sub", &m.KeySub},
+		{"fan", &m.Fan},
+		{"led", &m.Led},
+	}
+}
+
+func (e *PinNotFoundError) Error() string {
+	return fmt.Sprintf("gpio pin %q for %s not found", e.Name, e.Role)
+}
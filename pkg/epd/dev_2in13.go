@@ -1,9 +1,6 @@
 package epd
 
-import (
-	"errors"
-	"image"
-)
+import "image"
 
 var (
 	dev2in13LutFullUpdate = []byte{
@@ -33,9 +30,6 @@ var (
 		0x00, 0x00,	0x00, 0x00,	0x00, 0x00, 0x00, 0x00,	0x00, 0x00,
 		0x15, 0x41,	0xA8, 0x32,	0x50, 0x2C, 0x0B,
 	}
-
-	dev2in13Width  = 122
-	dev2in13Height = 250
 )
 
 const (
@@ -44,36 +38,37 @@ const (
 )
 
 type device interface {
+	isConnected() bool
 	initBoard() error
 	init(partial bool) error
-	clear(bgColor byte)
-	display(img image.RGBA)
-	sleep()
-	reset()
+	clear(bgColor byte) error
+	display(f *frame) error
+	sleep() error
+	reset() error
 }
 
+// dev2in13 is the Waveshare 2.13inch V2 panel
 type dev2in13 struct {
-	board *board
-	partial bool
+	driver
 }
 
-func newDev2in13(board *board) device {
-	return &dev2in13{board, false}
+func init() {
+	registerPanel(Panel{
+		Name:      "2in13v2",
+		Width:     122,
+		Height:    250,
+		Partial:   true,
+		newDevice: newDev2in13,
+	})
 }
 
-func (d *dev2in13) initBoard() error {
-	if !d.board.isConnected() {
-		if err := d.board.init(); err != nil {
-			return err
-		}
-	}
-
-	return nil
+func newDev2in13(board *board, panel Panel) device {
+	return &dev2in13{driver{board: board, panel: panel}}
 }
 
 func (d *dev2in13) init(partial bool) error {
 	if !d.board.isConnected() {
-		return errors.New("board is not inited")
+		return errBoardNotInited
 	}
 
 	d.partial = partial
@@ -84,60 +79,59 @@ func (d *dev2in13) init(partial bool) error {
 		d.initLutFull()
 	}
 
-	return nil
+	return d.done()
 }
 
-func (d *dev2in13) clear(bgColor byte) {
-	d.setWindow(0, 0, uint(dev2in13Width-1), uint(dev2in13Height-1))
-	for j := 0; j < dev2in13Height; j++ {
-		d.setCursor(0, uint(j))
-		d.sendCmd(0x24)
-		for i := 0; i <= dev2in13Width/8; i++ {
-			d.sendData(bgColor)
-		}
-	}
+func (d *dev2in13) clear(bgColor byte) error {
+	d.writeRAM(d.filledBuffer(bgColor), fullRegion(d.panel))
 	d.turnOnDisplay()
-}
 
-func (d *dev2in13) display(img image.RGBA) {
-	d.setWindow(0, 0, uint(dev2in13Width-1), uint(dev2in13Height-1))
-
-	vertical := d.isVertical(img)
-
-	for y := 0; y < dev2in13Height; y++ {
-		d.setCursor(0, uint(y))
-		d.sendCmd(0x24)
-		yy := y
+	return d.done()
+}
 
-		if !vertical {
-			yy = dev2in13Height - y - 1
-		}
-
-		for x := 0; x <= dev2in13Width/8; x++ {
-			d.sendData(getImageByte(yy, x, img, vertical))
-		}
-	}
+func (d *dev2in13) display(f *frame) error {
+	d.writeRAM(f.black, fullRegion(d.panel))
 
 	if d.partial {
 		d.turnOnDisplayPartial()
 	} else {
 		d.turnOnDisplay()
 	}
+
+	return d.done()
 }
 
-func (d *dev2in13) isVertical(img image.RGBA) bool {
-	return img.Rect.Dx() == dev2in13Width && img.Rect.Dy() == dev2in13Height
+func (d *dev2in13) displayRegions(f *frame, regions []image.Rectangle) error {
+	for _, r := range regions {
+		d.writeRAM(f.black, r)
+	}
+
+	d.turnOnDisplayPartial()
+
+	return d.done()
 }
 
-func (d *dev2in13) sleep() {
+// writeRAM writes the region of the buffer to the black and white RAM with a single data transfer
+func (d *dev2in13) writeRAM(buf []byte, r image.Rectangle) {
+	// x and y increment, so the rows are written one after another starting from the cursor
+	d.sendCmd(0x11)
+	d.sendData(0x03)
+	d.setWindow(uint(r.Min.X), uint(r.Min.Y), uint(r.Max.X-1), uint(r.Max.Y-1))
+	d.setCursor(uint(r.Min.X), uint(r.Min.Y))
+	d.sendCmd(0x24)
+	d.sendData(windowBytes(buf, d.panel, r)...)
+}
+
+func (d *dev2in13) sleep() error {
 	d.sendCmd(0x10)
 	d.sendData(0x01)
-	d.board.cleanup()
+
+	return d.sleepAndCleanup()
 }
 
 func (d *dev2in13) initLutPartial()  {
-	d.reset()
-	d.board.readBUSY()
+	d.hwReset()
+	d.waitBusy()
 	d.sendCmd(0x32)
 	d.sendData(dev2in13LutPartialUpdate...)
 	d.sendCmd(0x37)
@@ -152,14 +146,14 @@ func (d *dev2in13) initLutPartial()  {
 	d.sendCmd(0x22)
 	d.sendData(0xC0)
 	d.sendCmd(0x20)
-	d.board.readBUSY()
+	d.waitBusy()
 }
 
 func (d *dev2in13) initLutFull() {
-	d.reset()
-	d.board.readBUSY()
+	d.hwReset()
+	d.waitBusy()
 	d.sendCmd(0x12)
-	d.board.readBUSY()
+	d.waitBusy()
 
 	d.sendCmd(0x74)
 	d.sendData(0x54)
@@ -211,30 +205,7 @@ func (d *dev2in13) initLutFull() {
 	d.sendCmd(0x4F)
 	d.sendData(0xF9)
 	d.sendData(0x00)
-	d.board.readBUSY()
-}
-
-func (d *dev2in13) reset() {
-	d.board.writeRST(true)
-	d.board.delayms(200)
-	d.board.writeRST(false)
-	d.board.delayms(10)
-	d.board.writeRST(true)
-	d.board.delayms(200)
-}
-
-func (d *dev2in13) sendCmd(b byte) {
-	d.board.writeDC(false)
-	d.board.writeCS(false)
-	d.board.writeSPI(b)
-	d.board.writeCS(true)
-}
-
-func (d *dev2in13) sendData(b ...byte) {
-	d.board.writeDC(true)
-	d.board.writeCS(false)
-	d.board.writeSPI(b...)
-	d.board.writeCS(true)
+	d.waitBusy()
 }
 
 func (d *dev2in13) turnOnDisplay() {
@@ -242,7 +213,7 @@ func (d *dev2in13) turnOnDisplay() {
 	d.sendData(0xc7)
 	d.sendCmd(0x20)
 	d.sendCmd(0xff)
-	d.board.readBUSY()
+	d.waitBusy()
 }
 
 func (d *dev2in13) turnOnDisplayPartial() {
@@ -250,7 +221,7 @@ func (d *dev2in13) turnOnDisplayPartial() {
 	d.sendData(0x0c)
 	d.sendCmd(0x20)
 	d.sendCmd(0xff)
-	d.board.readBUSY()
+	d.waitBusy()
 }
 
 func (d *dev2in13) setWindow(xStart, yStart, xEnd, yEnd uint) {
@@ -272,5 +243,5 @@ func (d *dev2in13) setCursor(x, y uint) {
 	d.sendCmd(0x4f)
 	d.sendData(byte(y & 0xff))
 	d.sendData(byte((y >> 8) & 0xff))
-	d.board.readBUSY()
+	d.waitBusy()
 }
@@ -0,0 +1,93 @@
+package epd
+
+// dev4in2 is the Waveshare 4.2inch panel on the IL0398 controller, it only supports full refresh
+type dev4in2 struct {
+	driver
+}
+
+func init() {
+	registerPanel(Panel{
+		Name:      "4in2",
+		Width:     400,
+		Height:    300,
+		newDevice: newDev4in2,
+	})
+}
+
+func newDev4in2(board *board, panel Panel) device {
+	return &dev4in2{driver{board: board, panel: panel}}
+}
+
+func (d *dev4in2) init(partial bool) error {
+	if !d.board.isConnected() {
+		return errBoardNotInited
+	}
+
+	d.hwReset()
+
+	d.sendCmd(0x01) // power setting
+	d.sendData(0x03, 0x00, 0x2B, 0x2B)
+
+	d.sendCmd(0x06) // booster soft start
+	d.sendData(0x17, 0x17, 0x17)
+
+	d.sendCmd(0x04) // power on
+	d.waitBusy()
+
+	d.sendCmd(0x00) // panel setting: black and white, LUT from OTP
+	d.sendData(0x1F)
+
+	d.sendCmd(0x30) // PLL: 100Hz
+	d.sendData(0x3C)
+
+	d.sendCmd(0x61) // resolution
+	d.sendData(
+		byte((d.panel.Width>>8)&0xff), byte(d.panel.Width&0xff),
+		byte((d.panel.Height>>8)&0xff), byte(d.panel.Height&0xff),
+	)
+
+	d.sendCmd(0x82) // VCOM DC
+	d.sendData(0x28)
+
+	d.sendCmd(0x50) // VCOM and data interval: white border
+	d.sendData(0x97)
+
+	return d.done()
+}
+
+func (d *dev4in2) clear(bgColor byte) error {
+	d.sendCmd(0x10)
+	d.sendData(d.filledBuffer(bgColor)...)
+	d.sendCmd(0x13)
+	d.sendData(d.filledBuffer(bgColor)...)
+	d.turnOnDisplay()
+
+	return d.done()
+}
+
+func (d *dev4in2) display(f *frame) error {
+	d.sendCmd(0x13)
+	d.sendData(f.black...)
+	d.turnOnDisplay()
+
+	return d.done()
+}
+
+func (d *dev4in2) sleep() error {
+	d.sendCmd(0x02) // power off
+	d.waitBusy()
+	d.sendCmd(0x07) // deep sleep
+	d.sendData(0xA5)
+
+	return d.sleepAndCleanup()
+}
+
+func (d *dev4in2) turnOnDisplay() {
+	d.sendCmd(0x12)
+	d.waitBusy()
+}
+
+// waitBusy waits while the BUSY pin is low, the IL0398 reports being busy with the low level
+func (d *dev4in2) waitBusy() {
+	d.waitBusyLevel(false)
+}
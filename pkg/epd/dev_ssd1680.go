@@ -0,0 +1,195 @@
+package epd
+
+import "image"
+
+// devSSD1680 drives the panels on the SSD1680 controller: Waveshare 2.13inch V3, 2.13inch (B) V4
+// and 2.9inch V2. The waveforms stored in the controller OTP are used instead of uploading a LUT.
+type devSSD1680 struct {
+	driver
+}
+
+func init() {
+	registerPanel(Panel{
+		Name:      "2in13v3",
+		Width:     122,
+		Height:    250,
+		Partial:   true,
+		newDevice: newDevSSD1680,
+	})
+
+	registerPanel(Panel{
+		Name:      "2in13b",
+		Width:     122,
+		Height:    250,
+		Red:       true,
+		newDevice: newDevSSD1680,
+	})
+
+	registerPanel(Panel{
+		Name:      "2in9",
+		Width:     128,
+		Height:    296,
+		newDevice: newDevSSD1680,
+	})
+}
+
+func newDevSSD1680(board *board, panel Panel) device {
+	return &devSSD1680{driver{board: board, panel: panel}}
+}
+
+func (d *devSSD1680) init(partial bool) error {
+	if !d.board.isConnected() {
+		return errBoardNotInited
+	}
+
+	d.partial = partial && d.panel.Partial
+
+	d.hwReset()
+	d.waitBusy()
+
+	if d.partial {
+		d.sendCmd(0x3C) // border waveform
+		d.sendData(0x80)
+		d.initRAM()
+
+		return d.done()
+	}
+
+	d.sendCmd(0x12) // software reset
+	d.waitBusy()
+
+	d.initRAM()
+
+	d.sendCmd(0x3C) // border waveform
+	d.sendData(0x05)
+
+	d.sendCmd(0x21) // display update control
+	if d.panel.Red {
+		// the red RAM is inverted, so a set bit is a not red pixel as in the black RAM
+		d.sendData(0x80, 0x80)
+	} else {
+		d.sendData(0x00, 0x80)
+	}
+
+	d.sendCmd(0x18) // internal temperature sensor
+	d.sendData(0x80)
+	d.waitBusy()
+
+	return d.done()
+}
+
+func (d *devSSD1680) clear(bgColor byte) error {
+	d.setWindow(0, 0, uint(d.panel.Width-1), uint(d.panel.Height-1))
+	d.setCursor(0, 0)
+	d.sendCmd(0x24)
+	d.sendData(d.filledBuffer(bgColor)...)
+	d.sendCmd(0x26)
+	if d.panel.Red {
+		// no red pixels
+		d.sendData(d.filledBuffer(0xff)...)
+	} else {
+		d.sendData(d.filledBuffer(bgColor)...)
+	}
+	d.turnOnDisplay()
+
+	return d.done()
+}
+
+func (d *devSSD1680) display(f *frame) error {
+	d.setWindow(0, 0, uint(d.panel.Width-1), uint(d.panel.Height-1))
+	d.setCursor(0, 0)
+	d.sendCmd(0x24)
+	d.sendData(f.black...)
+
+	if d.panel.Red {
+		d.sendCmd(0x26)
+		d.sendData(f.red...)
+		d.turnOnDisplay()
+
+		return d.done()
+	}
+
+	if d.partial {
+		d.turnOnDisplayPartial()
+		return d.done()
+	}
+
+	// the partial refresh compares the new image with the base one in the RAM 0x26
+	d.sendCmd(0x26)
+	d.sendData(f.black...)
+	d.turnOnDisplay()
+
+	return d.done()
+}
+
+func (d *devSSD1680) displayRegions(f *frame, regions []image.Rectangle) error {
+	if !d.partial {
+		return d.display(f)
+	}
+
+	for _, r := range regions {
+		d.setWindow(uint(r.Min.X), uint(r.Min.Y), uint(r.Max.X-1), uint(r.Max.Y-1))
+		d.setCursor(uint(r.Min.X), uint(r.Min.Y))
+		d.sendCmd(0x24)
+		d.sendData(windowBytes(f.black, d.panel, r)...)
+	}
+
+	d.turnOnDisplayPartial()
+
+	return d.done()
+}
+
+func (d *devSSD1680) sleep() error {
+	d.sendCmd(0x10)
+	d.sendData(0x01)
+
+	return d.sleepAndCleanup()
+}
+
+func (d *devSSD1680) initRAM() {
+	h := d.panel.Height - 1
+
+	d.sendCmd(0x01) // driver output control
+	d.sendData(byte(h&0xff), byte((h>>8)&0xff), 0x00)
+
+	d.sendCmd(0x11) // data entry mode: x and y increment
+	d.sendData(0x03)
+
+	d.setWindow(0, 0, uint(d.panel.Width-1), uint(d.panel.Height-1))
+	d.setCursor(0, 0)
+}
+
+func (d *devSSD1680) turnOnDisplay() {
+	d.sendCmd(0x22)
+	d.sendData(0xF7)
+	d.sendCmd(0x20)
+	d.waitBusy()
+}
+
+func (d *devSSD1680) turnOnDisplayPartial() {
+	d.sendCmd(0x22)
+	d.sendData(0xFF)
+	d.sendCmd(0x20)
+	d.waitBusy()
+}
+
+func (d *devSSD1680) setWindow(xStart, yStart, xEnd, yEnd uint) {
+	d.sendCmd(0x44)
+	// x point must be the multiple of 8 or the last 3 bits will be ignored
+	d.sendData(byte((xStart >> 3) & 0xff))
+	d.sendData(byte((xEnd >> 3) & 0xff))
+	d.sendCmd(0x45)
+	d.sendData(byte(yStart & 0xff))
+	d.sendData(byte((yStart >> 8) & 0xff))
+	d.sendData(byte(yEnd & 0xff))
+	d.sendData(byte((yEnd >> 8) & 0xff))
+}
+
+func (d *devSSD1680) setCursor(x, y uint) {
+	d.sendCmd(0x4E)
+	d.sendData(byte((x >> 3) & 0xff))
+	d.sendCmd(0x4F)
+	d.sendData(byte(y & 0xff))
+	d.sendData(byte((y >> 8) & 0xff))
+	d.waitBusy()
+}
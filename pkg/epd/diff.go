@@ -0,0 +1,96 @@
+package epd
+
+import (
+	"bytes"
+	"image"
+)
+
+const (
+	// dirty rows closer than this are merged into one region, a window costs more than a few clean rows
+	regionMergeGap = 8
+	// more regions than this are merged into their bounding rectangle
+	maxRegions = 4
+)
+
+// regionDevice is a device able to write and partially refresh only the given regions of the RAM
+type regionDevice interface {
+	displayRegions(f *frame, regions []image.Rectangle) error
+}
+
+func (f *frame) equal(other *frame) bool {
+	return bytes.Equal(f.black, other.black) && bytes.Equal(f.red, other.red)
+}
+
+// dirtyRegions returns the rectangles in panel pixels covering everything that differs between
+// the frames. The x bounds are aligned to 8 pixels since the controller addresses the RAM by bytes.
+func dirtyRegions(prev, next *frame, panel Panel) []image.Rectangle {
+	stride := (panel.Width + 7) / 8
+
+	var regions []image.Rectangle
+
+	for y := 0; y < panel.Height; y++ {
+		first, last := -1, -1
+
+		for x := 0; x < stride; x++ {
+			i := y*stride + x
+
+			if prev.black[i] != next.black[i] || (next.red != nil && prev.red[i] != next.red[i]) {
+				if first == -1 {
+					first = x
+				}
+
+				last = x
+			}
+		}
+
+		if first == -1 {
+			continue
+		}
+
+		row := image.Rect(first*8, y, (last+1)*8, y+1)
+		n := len(regions)
+
+		if n > 0 && y-regions[n-1].Max.Y < regionMergeGap {
+			regions[n-1] = regions[n-1].Union(row)
+		} else {
+			regions = append(regions, row)
+		}
+	}
+
+	if len(regions) > maxRegions {
+		bounds := regions[0]
+
+		for _, r := range regions[1:] {
+			bounds = bounds.Union(r)
+		}
+
+		regions = []image.Rectangle{bounds}
+	}
+
+	for i := range regions {
+		if regions[i].Max.X > panel.Width {
+			regions[i].Max.X = panel.Width
+		}
+	}
+
+	return regions
+}
+
+// windowBytes returns the bytes of the buf covered by the region, row after row
+func windowBytes(buf []byte, panel Panel, r image.Rectangle) []byte {
+	stride := (panel.Width + 7) / 8
+	x0, x1 := r.Min.X/8, (r.Max.X+7)/8
+
+	data := make([]byte, 0, (x1-x0)*r.Dy())
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		data = append(data, buf[y*stride+x0:y*stride+x1]...)
+	}
+
+	return data
+}
+
+// fullRegion is the region of the whole panel
+func fullRegion(panel Panel) image.Rectangle {
+	return image.Rect(0, 0, panel.Width, panel.Height)
+}
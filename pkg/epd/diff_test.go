@@ -0,0 +1,136 @@
+package epd
+
+import (
+	"bytes"
+	"image"
+	"reflect"
+	"testing"
+)
+
+// flipped returns a copy of the frame with the pixels turned the other color
+func flipped(f *frame, panel Panel, pixels ...image.Point) *frame {
+	stride := (panel.Width + 7) / 8
+	next := &frame{black: append([]byte{}, f.black...)}
+
+	for _, p := range pixels {
+		next.black[p.Y*stride+p.X/8] ^= 0x80 >> uint(p.X%8)
+	}
+
+	return next
+}
+
+func TestDirtyRegions(t *testing.T) {
+	panel, err := LookupPanel("2in13v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stride := (panel.Width + 7) / 8
+	blank := &frame{black: make([]byte, stride*panel.Height)}
+	inverted := &frame{black: filled(stride*panel.Height, 0xff)}
+
+	tests := []struct {
+		name string
+		next *frame
+		want []image.Rectangle
+	}{
+		{
+			name: "no change",
+			next: blank,
+		},
+		{
+			name: "byte aligned x",
+			next: flipped(blank, panel, image.Pt(13, 5)),
+			want: []image.Rectangle{image.Rect(8, 5, 16, 6)},
+		},
+		{
+			name: "clipped to the width",
+			next: flipped(blank, panel, image.Pt(121, 7)),
+			want: []image.Rectangle{image.Rect(120, 7, 122, 8)},
+		},
+		{
+			name: "close rows merged",
+			next: flipped(blank, panel, image.Pt(3, 5), image.Pt(20, 10)),
+			want: []image.Rectangle{image.Rect(0, 5, 24, 11)},
+		},
+		{
+			name: "distant rows apart",
+			next: flipped(blank, panel, image.Pt(3, 5), image.Pt(20, 6+regionMergeGap)),
+			want: []image.Rectangle{image.Rect(0, 5, 8, 6), image.Rect(16, 6+regionMergeGap, 24, 7+regionMergeGap)},
+		},
+		{
+			name: "too many regions bounded",
+			next: flipped(blank, panel, image.Pt(0, 0), image.Pt(30, 20), image.Pt(60, 40), image.Pt(90, 60), image.Pt(40, 80)),
+			want: []image.Rectangle{image.Rect(0, 0, 96, 81)},
+		},
+		{
+			name: "full frame",
+			next: inverted,
+			want: []image.Rectangle{fullRegion(panel)},
+		},
+	}
+
+	for _, test := range tests {
+		got := dirtyRegions(blank, test.next, panel)
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestWindowBytes(t *testing.T) {
+	panel, err := LookupPanel("2in13v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stride := (panel.Width + 7) / 8
+	buf := make([]byte, stride*panel.Height)
+
+	for i := range buf {
+		buf[i] = byte(i)
+	}
+
+	at := func(x, y int) byte {
+		return buf[y*stride+x]
+	}
+
+	tests := []struct {
+		name   string
+		region image.Rectangle
+		want   []byte
+	}{
+		{
+			name:   "one byte",
+			region: image.Rect(8, 2, 16, 3),
+			want:   []byte{at(1, 2)},
+		},
+		{
+			name:   "rows",
+			region: image.Rect(8, 1, 24, 3),
+			want:   []byte{at(1, 1), at(2, 1), at(1, 2), at(2, 2)},
+		},
+		{
+			name:   "unaligned end",
+			region: image.Rect(8, 0, 20, 1),
+			want:   []byte{at(1, 0), at(2, 0)},
+		},
+		{
+			name:   "last byte of a row",
+			region: image.Rect(120, 4, 122, 5),
+			want:   []byte{at(stride-1, 4)},
+		},
+		{
+			name:   "full frame",
+			region: fullRegion(panel),
+			want:   buf,
+		},
+	}
+
+	for _, test := range tests {
+		if got := windowBytes(buf, panel, test.region); !bytes.Equal(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package epd
+
+import "errors"
+
+var errBoardNotInited = errors.New("board is not inited")
+
+// driver is the part shared by all the panel drivers. The writes of an operation stop at the
+// first failure which is kept in err and returned by done at the end of the operation.
+type driver struct {
+	board   *board
+	panel   Panel
+	partial bool
+	err     error
+}
+
+func (d *driver) isConnected() bool {
+	return d.board.isConnected()
+}
+
+func (d *driver) initBoard() error {
+	if !d.board.isConnected() {
+		if err := d.board.init(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *driver) reset() error {
+	d.hwReset()
+
+	return d.done()
+}
+
+// done returns the first error of the operation and gets the driver ready for the next one
+func (d *driver) done() error {
+	err := d.err
+	d.err = nil
+
+	return err
+}
+
+func (d *driver) hwReset() {
+	if d.err == nil {
+		d.err = d.board.transport().Reset()
+	}
+}
+
+func (d *driver) sendCmd(b byte) {
+	if d.err == nil {
+		d.err = d.board.transport().Command(b)
+	}
+}
+
+func (d *driver) sendData(b ...byte) {
+	if d.err == nil {
+		d.err = d.board.transport().Data(b...)
+	}
+}
+
+// waitBusy waits while the BUSY pin is high, which is how the SSD16xx controllers report being busy
+func (d *driver) waitBusy() {
+	d.waitBusyLevel(true)
+}
+
+func (d *driver) waitBusyLevel(level bool) {
+	if d.err == nil {
+		d.err = d.board.transport().WaitBusy(level)
+	}
+}
+
+// sleepAndCleanup finishes the sleep operation, the board is cleaned up even if the panel failed
+func (d *driver) sleepAndCleanup() error {
+	err := d.done()
+
+	if cerr := d.board.cleanup(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func (d *driver) bufferSize() int {
+	return (d.panel.Width + 7) / 8 * d.panel.Height
+}
+
+func (d *driver) filledBuffer(b byte) []byte {
+	return filled(d.bufferSize(), b)
+}
+
+func filled(size int, b byte) []byte {
+	buf := make([]byte, size)
+
+	for i := range buf {
+		buf[i] = b
+	}
+
+	return buf
+}
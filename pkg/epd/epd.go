@@ -3,12 +3,13 @@ package epd
 import (
 	"errors"
 	"image"
-	"os"
-	"os/signal"
 	"sync"
-	"syscall"
+	"time"
 )
 
+// ErrNotConnected is returned when the e-paper is used before InitBoard
+var ErrNotConnected = errors.New("the board is not connected, please call InitBoard first")
+
 const (
 	BtnOk = iota
 	BtnBack
@@ -18,126 +19,271 @@ const (
 
 // Epaper is a e-paper device
 type Epaper struct {
-	board  *board
-	device device
-	mu     sync.Mutex
+	board      *board
+	device     device
+	panel      Panel
+	conversion Conversion
+	// last is the frame in the panel RAM, nil when unknown
+	last       *frame
+	partial    bool
+	puCnt      int
+	fullAt     time.Time
+	mu         sync.Mutex
+	// pwmMu guards the fan and the led apart from mu, so they are not stalled by a refresh waiting for the
+	// busy panel. Connecting and releasing the board hold both, mu first.
+	pwmMu      sync.Mutex
 }
 
-// New creates a new e-paper device
-func New(powerOffOnExit bool) *Epaper {
-	b := &board{}
-	d := newDev2in13(b)
+// New creates a new e-paper device wired to the Raspberry Pi as described by the cfg
+func New(cfg Config) (*Epaper, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return NewWithProvider(PeriphProvider{Config: cfg}, cfg)
+}
+
+// NewWithProvider creates a new e-paper device on the hardware opened by the provider,
+// only the panel option of the cfg is used
+func NewWithProvider(provider Provider, cfg Config) (*Epaper, error) {
+	panel, err := LookupPanel(cfg.Panel)
+	if err != nil {
+		return nil, err
+	}
+
+	b := newBoard(provider)
 
 	paper := &Epaper{
 		board:  b,
-		device: d,
+		device: panel.newDevice(b, panel),
+		panel:  panel,
 	}
 
-	if powerOffOnExit {
-		paper.setExitSignalListener()
-	}
+	return paper, nil
+}
 
-	return paper
+// Panel returns the panel the e-paper drives
+func (p *Epaper) Panel() Panel {
+	return p.panel
 }
 
-// Display display img on e-paper
+// Display display img on e-paper, img is converted to 1-bit with the conversion set by SetConversion
 func (p *Epaper) Display(img image.RGBA) error {
+	return p.DisplayConverted(img, p.Conversion())
+}
+
+// DisplayConverted display img on e-paper converting it to 1-bit with the conv
+func (p *Epaper) DisplayConverted(img image.RGBA, conv Conversion) (err error) {
+	f := newFrame(img, p.panel, conv)
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	if !p.board.isConnected() {
-		return errors.New("the board is not connected, please call InitBoard first")
+	if !p.device.isConnected() {
+		return ErrNotConnected
 	}
 
-	p.device.display(img)
+	if p.last != nil && p.last.equal(f) {
+		return nil
+	}
+
+	rd, ok := p.device.(regionDevice)
+
+	if ok && p.partial && p.last != nil {
+		err = rd.displayRegions(f, dirtyRegions(p.last, f, p.panel))
+	} else {
+		err = p.device.display(f)
+	}
+
+	if err != nil {
+		// the panel RAM is not known after a failed write
+		p.last = nil
+		return err
+	}
+
+	p.last = f
+	p.countRefresh(p.partial)
+
 	return nil
 }
 
+// SetConversion sets the conversion used by Display
+func (p *Epaper) SetConversion(conv Conversion) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.conversion = conv
+}
+
+// Conversion returns the conversion used by Display
+func (p *Epaper) Conversion() Conversion {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.conversion
+}
+
 func (p *Epaper) InitBoard() error  {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pwmMu.Lock()
+	defer p.pwmMu.Unlock()
+
 	return p.device.initBoard()
 }
 
 func (p *Epaper) InitPartial() error  {
-	return p.device.init(true)
+	return p.init(true)
 }
 
 func (p *Epaper) InitFull() error  {
-	return p.device.init(false)
+	return p.init(false)
 }
 
 // Clear clear the e-paper screen
-func (p *Epaper) Clear(bgColor byte) {
-	p.device.clear(bgColor)
-}
-
-func (p *Epaper) Reset()  {
-	p.device.reset()
-}
-
-func (p *Epaper) Sleep() {
-	p.device.sleep()
-}
-
-func (p *Epaper) ReadButtons(btnChan chan int) {
-	go func() {
-		for {
-			if p.board.keyOkPressed() {
-				btnChan <- BtnOk
-			}
-		}
-	}()
-
-	go func() {
-		for {
-			if p.board.keyBackPressed() {
-				btnChan <- BtnBack
-			}
-		}
-	}()
-
-	go func() {
-		for {
-			if p.board.keyAddPressed() {
-				btnChan <- BtnAdd
-			}
-		}
-	}()
-
-	go func() {
-		for {
-			if p.board.keySubPressed() {
-				btnChan <- BtnSub
-			}
-		}
-	}()
-}
-
-func (p *Epaper) StartFan() {
-	if p.board.isConnected() {
-		p.board.writeFAN(true)
+func (p *Epaper) Clear(bgColor byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.device.clear(bgColor); err != nil {
+		p.last = nil
+		return err
+	}
+
+	p.countRefresh(false)
+
+	stride := (p.panel.Width + 7) / 8
+	p.last = &frame{black: filled(stride*p.panel.Height, bgColor)}
+
+	if p.panel.Red {
+		p.last.red = filled(stride*p.panel.Height, 0xff)
+	}
+
+	return nil
+}
+
+// PartialRefreshes returns the number of partial refreshes since the last full one
+func (p *Epaper) PartialRefreshes() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.puCnt
+}
+
+// LastFullRefresh returns the time of the last full refresh, zero if there was none
+func (p *Epaper) LastFullRefresh() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.fullAt
+}
+
+// SetLastFullRefresh restores the time of the last full refresh e.g. from before a restart when the panel
+// is not cleared on start, the refresh policies count the age of the partial refreshes from it
+func (p *Epaper) SetLastFullRefresh(t time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.fullAt = t
+}
+
+func (p *Epaper) countRefresh(partial bool) {
+	if partial {
+		p.puCnt++
+		return
 	}
+
+	p.puCnt = 0
+	p.fullAt = time.Now()
+}
+
+func (p *Epaper) init(partial bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// the RAM content is not trusted after the controller reset
+	p.last = nil
+	p.partial = partial && p.panel.Partial
+
+	return p.device.init(partial)
+}
+
+func (p *Epaper) Reset() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.device.reset()
+}
+
+func (p *Epaper) Sleep() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pwmMu.Lock()
+	defer p.pwmMu.Unlock()
+
+	p.last = nil
+
+	return p.device.sleep()
 }
 
-func (p *Epaper) StopFan() {
-	if p.board.isConnected() {
-		p.board.writeFAN(false)
+func (p *Epaper) StartFan() error {
+	return p.SetFanDuty(1)
+}
+
+func (p *Epaper) StopFan() error {
+	return p.SetFanDuty(0)
+}
+
+// SetFanDuty sets the fan speed as the PWM duty cycle 0-1
+func (p *Epaper) SetFanDuty(duty float64) error {
+	p.pwmMu.Lock()
+	defer p.pwmMu.Unlock()
+
+	if !p.board.isConnected() {
+		return ErrNotConnected
 	}
+
+	return p.board.setFanDuty(duty)
 }
 
-func (p *Epaper) OnLed()  {
-	p.board.writeLED(true)
+// FanDuty returns the fan PWM duty cycle 0-1
+func (p *Epaper) FanDuty() float64 {
+	p.pwmMu.Lock()
+	defer p.pwmMu.Unlock()
+
+	return p.board.fanDuty
 }
 
-func (p *Epaper) OffLed()  {
-	p.board.writeLED(false)
+func (p *Epaper) OnLed() error {
+	return p.SetLedDuty(1)
 }
 
-func (p *Epaper) setExitSignalListener()  {
-	c := make(chan os.Signal)
+func (p *Epaper) OffLed() error {
+	return p.SetLedDuty(0)
+}
+
+// SetLedDuty sets the led brightness as the PWM duty cycle 0-1
+func (p *Epaper) SetLedDuty(duty float64) error {
+	p.pwmMu.Lock()
+	defer p.pwmMu.Unlock()
+
+	if !p.board.isConnected() {
+		return ErrNotConnected
+	}
+
+	return p.board.led.SetDuty(duty)
+}
+
+// Close turns the fan and the led off and releases the board, the panel is left as it is.
+// Sleep also releases the board, Close does nothing then.
+func (p *Epaper) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pwmMu.Lock()
+	defer p.pwmMu.Unlock()
+
+	if !p.board.isConnected() {
+		return nil
+	}
 
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-c
-		p.board.cleanup()
-		os.Exit(1)
-	}()
+	return p.board.cleanup()
 }
@@ -0,0 +1,43 @@
+package epd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPWMIsNotStalledByRefresh(t *testing.T) {
+	paper, sim := newSimulated(t, "2in13v2")
+
+	// a refresh holds the lock while it waits for the busy panel
+	paper.mu.Lock()
+	defer paper.mu.Unlock()
+
+	done := make(chan error, 2)
+
+	go func() {
+		done <- paper.SetLedDuty(0.5)
+	}()
+
+	go func() {
+		done <- paper.SetFanDuty(0.7)
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("the pwm waits for the refresh")
+		}
+	}
+
+	if duty := sim.Hardware().Led.Duty(); duty != 0.5 {
+		t.Errorf("led duty %.2f, want 0.5", duty)
+	}
+
+	if duty := sim.Hardware().Fan.Duty(); duty != 0.7 {
+		t.Errorf("fan duty %.2f, want 0.7", duty)
+	}
+}
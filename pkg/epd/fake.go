@@ -0,0 +1,295 @@
+package epd
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TraceCommand = iota
+	TraceData
+	TraceBusy
+	TraceReset
+)
+
+// TraceEntry is a single operation recorded by the RecordingTransport,
+// Bytes of a TraceBusy entry hold the awaited busy level
+type TraceEntry struct {
+	Op    int
+	Bytes []byte
+}
+
+// RecordingTransport is a fake transport which records everything sent to the panel
+type RecordingTransport struct {
+	mu      sync.Mutex
+	entries []TraceEntry
+	closed  bool
+	err     error
+}
+
+// FakePin is a fake input and output pin. The level returned by Read follows the edges consumed
+// by WaitForEdge, so a reader always sees the level of the edge it has been woken up by.
+type FakePin struct {
+	mu     sync.Mutex
+	high   bool
+	edges  chan bool
+	writes []bool
+	duty   float64
+}
+
+// FakeProvider opens fake hardware made of a RecordingTransport and FakePins
+type FakeProvider struct {
+	Transport *RecordingTransport
+	KeyOk     *FakePin
+	KeyBack   *FakePin
+	KeyAdd    *FakePin
+	KeySub    *FakePin
+	Fan       *FakePin
+	Led       *FakePin
+}
+
+// NewFakeProvider creates fake hardware with all the buttons released
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{
+		Transport: &RecordingTransport{},
+		KeyOk:     NewFakePin(true),
+		KeyBack:   NewFakePin(true),
+		KeyAdd:    NewFakePin(true),
+		KeySub:    NewFakePin(true),
+		Fan:       NewFakePin(false),
+		Led:       NewFakePin(false),
+	}
+}
+
+// Open returns the fake hardware
+func (f *FakeProvider) Open() (*Hardware, error) {
+	return &Hardware{
+		Transport: f.Transport,
+		KeyOk:     f.KeyOk,
+		KeyBack:   f.KeyBack,
+		KeyAdd:    f.KeyAdd,
+		KeySub:    f.KeySub,
+		Fan:       f.Fan,
+		Led:       f.Led,
+	}, nil
+}
+
+// Key returns the fake pin of the button, btn is one of the Btn* constants
+func (f *FakeProvider) Key(btn int) *FakePin {
+	switch btn {
+	case BtnOk:
+		return f.KeyOk
+	case BtnBack:
+		return f.KeyBack
+	case BtnAdd:
+		return f.KeyAdd
+	case BtnSub:
+		return f.KeySub
+	}
+
+	return nil
+}
+
+func (t *RecordingTransport) Command(cmd byte) error {
+	return t.record(TraceCommand, cmd)
+}
+
+func (t *RecordingTransport) Data(data ...byte) error {
+	return t.record(TraceData, data...)
+}
+
+func (t *RecordingTransport) WaitBusy(level bool) error {
+	if level {
+		return t.record(TraceBusy, 1)
+	}
+
+	return t.record(TraceBusy, 0)
+}
+
+func (t *RecordingTransport) Reset() error {
+	return t.record(TraceReset)
+}
+
+// Fail makes all the following operations fail with err without being recorded, nil restores them
+func (t *RecordingTransport) Fail(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.err = err
+}
+
+func (t *RecordingTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = true
+
+	return nil
+}
+
+// Closed tells whether the transport has been closed
+func (t *RecordingTransport) Closed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.closed
+}
+
+// Entries returns a copy of the recorded trace
+func (t *RecordingTransport) Entries() []TraceEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entries := make([]TraceEntry, len(t.entries))
+	copy(entries, t.entries)
+
+	return entries
+}
+
+// Truncate drops the recorded trace
+func (t *RecordingTransport) Truncate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.entries = nil
+}
+
+// String formats the trace one operation per line, e.g. "cmd 0x12", "data 0x01 0xf9", "busy high",
+// which is handy for comparing with golden traces
+func (t *RecordingTransport) String() string {
+	var sb strings.Builder
+
+	for _, entry := range t.Entries() {
+		sb.WriteString(entry.String())
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+func (t *RecordingTransport) record(op int, b ...byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.err != nil {
+		return t.err
+	}
+
+	bytes := make([]byte, len(b))
+	copy(bytes, b)
+
+	t.entries = append(t.entries, TraceEntry{Op: op, Bytes: bytes})
+
+	return nil
+}
+
+func (e TraceEntry) String() string {
+	var name string
+
+	switch e.Op {
+	case TraceCommand:
+		name = "cmd"
+	case TraceData:
+		name = "data"
+	case TraceBusy:
+		// the byte is the busy level, not the data sent
+		if len(e.Bytes) == 1 && e.Bytes[0] == 0 {
+			return "busy low"
+		}
+
+		return "busy high"
+	case TraceReset:
+		name = "reset"
+	}
+
+	for _, b := range e.Bytes {
+		name += fmt.Sprintf(" 0x%02x", b)
+	}
+
+	return name
+}
+
+// NewFakePin creates a fake pin with the initial level
+func NewFakePin(high bool) *FakePin {
+	return &FakePin{
+		high:  high,
+		edges: make(chan bool, 64),
+	}
+}
+
+// Set drives the input level and emits an edge
+func (p *FakePin) Set(high bool) {
+	p.edges <- high
+}
+
+// Writes returns all the levels written with Out
+func (p *FakePin) Writes() []bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	writes := make([]bool, len(p.writes))
+	copy(writes, p.writes)
+
+	return writes
+}
+
+func (p *FakePin) Out(high bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.high = high
+	p.writes = append(p.writes, high)
+}
+
+// SetDuty records the duty, the level is high for any non zero duty
+func (p *FakePin) SetDuty(duty float64) error {
+	if duty < 0 || duty > 1 {
+		return ErrInvalidDuty
+	}
+
+	p.mu.Lock()
+	p.duty = duty
+	p.mu.Unlock()
+
+	p.Out(duty > 0)
+
+	return nil
+}
+
+// Duty returns the last duty set with SetDuty
+func (p *FakePin) Duty() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.duty
+}
+
+func (p *FakePin) Read() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.high
+}
+
+func (p *FakePin) WaitForEdge(timeout time.Duration) bool {
+	var timeoutChan <-chan time.Time
+
+	if timeout >= 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutChan = timer.C
+	}
+
+	select {
+	case high := <-p.edges:
+		p.mu.Lock()
+		p.high = high
+		p.mu.Unlock()
+
+		return true
+	case <-timeoutChan:
+		return false
+	}
+}
@@ -1,41 +1,240 @@
 package epd
 
 import (
+	"fmt"
 	"image"
-	"image/color"
 )
 
-func getImageByte(j, i int, img image.RGBA, vertical bool) byte {
-	var b byte
-	var pixelValue int
+const (
+	// ConvertThreshold makes a pixel white when its luminance reaches the level
+	ConvertThreshold = iota
+	// ConvertOrdered is the ordered dithering with the 4x4 Bayer matrix
+	ConvertOrdered
+	// ConvertFloydSteinberg is the Floyd–Steinberg error diffusion dithering
+	ConvertFloydSteinberg
+)
+
+const defaultThresholdLevel = 128
+
+var conversionModeNames = map[string]int{
+	"threshold": ConvertThreshold,
+	"ordered":   ConvertOrdered,
+	"fs":        ConvertFloydSteinberg,
+}
+
+var bayer4x4 = [4][4]int{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// Conversion describes how an image is converted to the 1-bit one the panel shows
+type Conversion struct {
+	Mode int
+	// Level is the luminance 1-255 from which a pixel is white in the threshold mode, 0 means 128
+	Level uint8
+}
 
-	for x := 0; x < 8; x++ {
-		xx := i*8 + x
+// frame is an image packed the same way as the panel RAM: rows of the native panel orientation,
+// 8 pixels per byte, the most significant bit first
+type frame struct {
+	// black has a set bit for a white pixel
+	black []byte
+	// red has a set bit for a not red pixel, nil for black and white panels
+	red []byte
+}
+
+// ParseConversionMode returns the conversion mode by its name: threshold, ordered or fs
+func ParseConversionMode(name string) (int, error) {
+	mode, ok := conversionModeNames[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown conversion mode %q, use one of: threshold, ordered, fs", name)
+	}
+
+	return mode, nil
+}
+
+// newFrame converts the whole img for the panel, img is rotated if its size is not the native one
+func newFrame(img image.RGBA, panel Panel, conv Conversion) *frame {
+	switch conv.Mode {
+	case ConvertOrdered, ConvertFloydSteinberg:
+		return newDitheredFrame(img, panel, conv)
+	}
 
-		if vertical {
-			pixelValue = getPixelValue(xx, j, img)
-		} else {
-			pixelValue = getPixelValue(j, xx, img)
+	level := int(conv.Level)
+	if level == 0 {
+		level = defaultThresholdLevel
+	}
+
+	return newThresholdFrame(img, panel, level)
+}
+
+// newThresholdFrame packs img in one pass reading the pixels directly from Pix
+func newThresholdFrame(img image.RGBA, panel Panel, level int) *frame {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	vertical := w == panel.Width && h == panel.Height
+	stride := (panel.Width + 7) / 8
+	f := &frame{black: make([]byte, stride*panel.Height)}
+
+	if panel.Red {
+		f.red = filled(stride*panel.Height, 0xff)
+	}
+
+	// luminance is compared multiplied by 1000 to skip the division per pixel
+	level = level*1000 - 500
+
+	for y := 0; y < panel.Height; y++ {
+		row := y * stride
+
+		for x := 0; x < panel.Width; x++ {
+			// landscape images are rotated so the left image column is the bottom panel row
+			ix, iy := x, y
+			if !vertical {
+				ix, iy = panel.Height-y-1, x
+			}
+
+			if ix >= w || iy >= h {
+				continue
+			}
+
+			o := iy*img.Stride + ix*4
+			r, g, b := int(img.Pix[o]), int(img.Pix[o+1]), int(img.Pix[o+2])
+			idx := row + (x >> 3)
+			bit := byte(0x80 >> uint(x&7))
+
+			if f.red != nil && isRed(r, g, b) {
+				f.black[idx] |= bit
+				f.red[idx] &^= bit
+			} else if r*299+g*587+b*114 >= level {
+				f.black[idx] |= bit
+			}
 		}
+	}
+
+	return f
+}
+
+// newDitheredFrame converts img with one of the dithering modes
+func newDitheredFrame(img image.RGBA, panel Panel, conv Conversion) *frame {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	lum, red := luminance(img, panel.Red)
+	white := make([]bool, w*h)
 
-		if pixelValue > 0 {
-			b = b | (1 << uint(7-x))
+	if conv.Mode == ConvertOrdered {
+		ditherOrdered(lum, white, w)
+	} else {
+		ditherFloydSteinberg(lum, white, w, h)
+	}
+
+	vertical := w == panel.Width && h == panel.Height
+	stride := (panel.Width + 7) / 8
+	f := &frame{black: make([]byte, stride*panel.Height)}
+
+	if panel.Red {
+		f.red = filled(stride*panel.Height, 0xff)
+	}
+
+	for y := 0; y < panel.Height; y++ {
+		for x := 0; x < panel.Width; x++ {
+			ix, iy := x, y
+			if !vertical {
+				ix, iy = panel.Height-y-1, x
+			}
+
+			if ix >= w || iy >= h {
+				continue
+			}
+
+			idx := y*stride + x/8
+			bit := byte(1 << uint(7-x%8))
+			i := iy*w + ix
+
+			if red != nil && red[i] {
+				f.black[idx] |= bit
+				f.red[idx] &^= bit
+			} else if white[i] {
+				f.black[idx] |= bit
+			}
+		}
+	}
+
+	return f
+}
+
+// luminance returns the luminance 0-255 of every img pixel, and the mask of red pixels if withRed is set
+func luminance(img image.RGBA, withRed bool) ([]int, []bool) {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	lum := make([]int, w*h)
+
+	var red []bool
+	if withRed {
+		red = make([]bool, w*h)
+	}
+
+	for y := 0; y < h; y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+w*4]
+
+		for x := 0; x < w; x++ {
+			r, g, b := int(row[x*4]), int(row[x*4+1]), int(row[x*4+2])
+			lum[y*w+x] = (r*299 + g*587 + b*114 + 500) / 1000
+
+			if withRed && isRed(r, g, b) {
+				red[y*w+x] = true
+			}
 		}
 	}
 
-	return b
+	return lum, red
+}
+
+func isRed(r, g, b int) bool {
+	return r > 0x7f && r > g+0x40 && r > b+0x40
 }
 
-func getPixelValue(x, y int, img image.RGBA) int {
-	c := img.At(x, y)
-	if convertColorToBlackWhite(c) {
-		return 1
+func ditherOrdered(lum []int, white []bool, w int) {
+	for i, l := range lum {
+		x, y := i%w, i/w
+		threshold := (bayer4x4[y%4][x%4]*2 + 1) * 255 / 32
+		white[i] = l > threshold
 	}
-	return 0
 }
 
-func convertColorToBlackWhite(c color.Color) bool {
-	r, g, b, _ := c.RGBA()
-	grey := (r*299 + g*587 + b*114 + 500) / 1000
-	return grey >= 0x7fff
+func ditherFloydSteinberg(lum []int, white []bool, w, h int) {
+	// errors are kept in 1/16 units to stay in integers
+	levels := make([]int, len(lum))
+	for i, l := range lum {
+		levels[i] = l * 16
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			level := levels[i]
+			out := 0
+
+			if level >= defaultThresholdLevel*16 {
+				white[i] = true
+				out = 255 * 16
+			}
+
+			e := (level - out) / 16
+
+			if x+1 < w {
+				levels[i+1] += e * 7
+			}
+
+			if y+1 < h {
+				if x > 0 {
+					levels[i+w-1] += e * 3
+				}
+
+				levels[i+w] += e * 5
+
+				if x+1 < w {
+					levels[i+w+1] += e
+				}
+			}
+		}
+	}
 }
@@ -0,0 +1,191 @@
+package epd
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// benchImage returns a landscape 250x122 image with a gradient, so the pixels fall on both sides of the threshold
+func benchImage() image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 250, 122))
+
+	for y := 0; y < 122; y++ {
+		for x := 0; x < 250; x++ {
+			l := uint8((x + y) * 255 / (250 + 122))
+			img.SetRGBA(x, y, color.RGBA{R: l, G: l, B: l, A: 0xff})
+		}
+	}
+
+	return *img
+}
+
+// newFrameAt packs img the way the frames were packed before newThresholdFrame, calling img.At for every pixel
+func newFrameAt(img image.RGBA, panel Panel) *frame {
+	stride := (panel.Width + 7) / 8
+	f := &frame{black: make([]byte, stride*panel.Height)}
+
+	for y := 0; y < panel.Height; y++ {
+		for i := 0; i < stride; i++ {
+			var b byte
+
+			for bit := 0; bit < 8; bit++ {
+				x := i*8 + bit
+				if x >= panel.Width {
+					break
+				}
+
+				r, g, bl, _ := img.At(panel.Height-y-1, x).RGBA()
+				if (r*299+g*587+bl*114+500)/1000 >= 0x7fff {
+					b |= 1 << uint(7-bit)
+				}
+			}
+
+			f.black[y*stride+i] = b
+		}
+	}
+
+	return f
+}
+
+func TestThresholdFrameMatchesAt(t *testing.T) {
+	panel, err := LookupPanel("2in13v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img := benchImage()
+
+	if !newFrame(img, panel, Conversion{}).equal(newFrameAt(img, panel)) {
+		t.Error("the threshold frame differs from the one packed with img.At")
+	}
+}
+
+func BenchmarkNewFrame(b *testing.B) {
+	panel, err := LookupPanel("2in13v2")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	img := benchImage()
+
+	for _, mode := range []string{"threshold", "ordered", "fs"} {
+		conv := Conversion{Mode: conversionModeNames[mode]}
+
+		b.Run(mode, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				newFrame(img, panel, conv)
+			}
+		})
+	}
+
+	b.Run("at", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			newFrameAt(img, panel)
+		}
+	})
+}
+
+// grayImage returns a vertical image of the panel size filled with the luminance l
+func grayImage(panel Panel, l uint8) image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, panel.Width, panel.Height))
+
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = l, l, l, 0xff
+	}
+
+	return *img
+}
+
+// whitePixels counts the white pixels of the frame
+func (f *frame) whitePixels() int {
+	n := 0
+
+	for _, b := range f.black {
+		for ; b != 0; b &= b - 1 {
+			n++
+		}
+	}
+
+	return n
+}
+
+// frameWhite tells whether the pixel of the native panel orientation is white
+func frameWhite(f *frame, panel Panel, x, y int) bool {
+	stride := (panel.Width + 7) / 8
+
+	return f.black[y*stride+x/8]&(0x80>>uint(x%8)) != 0
+}
+
+func TestDitheredWhiteShare(t *testing.T) {
+	panel, err := LookupPanel("2in13v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total := panel.Width * panel.Height
+
+	for _, mode := range []string{"ordered", "fs"} {
+		for _, l := range []uint8{0, 32, 64, 128, 192, 255} {
+			f := newFrame(grayImage(panel, l), panel, Conversion{Mode: conversionModeNames[mode]})
+
+			share := float64(f.whitePixels()) / float64(total)
+			want := float64(l) / 255
+
+			if share < want-0.03 || share > want+0.03 {
+				t.Errorf("%s: gray %d has %.3f white pixels, want %.3f", mode, l, share, want)
+			}
+		}
+	}
+}
+
+func TestOrderedPattern(t *testing.T) {
+	panel, err := LookupPanel("2in13v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := newFrame(grayImage(panel, 128), panel, Conversion{Mode: ConvertOrdered})
+
+	// the 50% gray is white where the Bayer matrix is in its lower half, the tiles repeat over the frame
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			want := bayer4x4[y%4][x%4] < 8
+
+			if got := frameWhite(f, panel, x, y); got != want {
+				t.Errorf("pixel %d,%d white %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestFloydSteinbergKeepsGradient(t *testing.T) {
+	panel, err := LookupPanel("2in13v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img := benchImage()
+	f := newFrame(img, panel, Conversion{Mode: ConvertFloydSteinberg})
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+
+	// every quarter of the landscape gradient keeps its mean luminance, the left image column is the bottom
+	// panel row
+	for q := 0; q < 4; q++ {
+		var white, want float64
+
+		for ix := q * w / 4; ix < (q+1)*w/4; ix++ {
+			for iy := 0; iy < h; iy++ {
+				want += float64(img.Pix[iy*img.Stride+ix*4]) / 255
+
+				if frameWhite(f, panel, iy, panel.Height-ix-1) {
+					white++
+				}
+			}
+		}
+
+		if white < want*0.95 || white > want*1.05 {
+			t.Errorf("quarter %d has %.0f white pixels, want %.0f", q, white, want)
+		}
+	}
+}
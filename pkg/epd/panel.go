@@ -0,0 +1,60 @@
+package epd
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Panel describes an e-paper panel and its driver
+type Panel struct {
+	Name string
+	// Width and Height are the native resolution of the panel
+	Width  int
+	Height int
+	// Partial tells whether the panel supports partial refresh, panels without it always do a full refresh
+	Partial bool
+	// Red tells whether the panel is a three-color black, white and red one
+	Red bool
+
+	newDevice func(b *board, panel Panel) device
+}
+
+const defaultPanel = "2in13v2"
+
+var panels = map[string]Panel{}
+
+func registerPanel(panel Panel) {
+	panels[panel.Name] = panel
+}
+
+// LookupPanel returns the registered panel by its name
+func LookupPanel(name string) (Panel, error) {
+	panel, ok := panels[name]
+	if !ok {
+		return Panel{}, fmt.Errorf("unknown panel %q, supported panels: %v", name, PanelNames())
+	}
+
+	return panel, nil
+}
+
+// PanelNames returns the names of all registered panels
+func PanelNames() []string {
+	var names []string
+
+	for name := range panels {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Landscape returns the panel size with the long side horizontal
+func (p Panel) Landscape() (width, height int) {
+	if p.Width > p.Height {
+		return p.Width, p.Height
+	}
+
+	return p.Height, p.Width
+}
@@ -0,0 +1,258 @@
+package epd
+
+import (
+	"errors"
+	"time"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/spi"
+	"periph.io/x/periph/conn/spi/spireg"
+	"periph.io/x/periph/host"
+)
+
+const (
+	// default gpio pins
+	defaultPinRST     = "GPIO17"
+	defaultPinDC      = "GPIO25"
+	defaultPinCS      = "GPIO8"
+	defaultPinBUSY    = "GPIO24"
+	defaultPinKeyOk   = "GPIO5"
+	defaultPinKeyBack = "GPIO6"
+	defaultPinKeyAdd  = "GPIO13"
+	defaultPinKeySub  = "GPIO19"
+	defaultPinFan     = "GPIO18"
+	defaultPinLed     = "GPIO26"
+
+	busyPollInterval = 10 * time.Millisecond
+)
+
+// ErrBusyTimeout is returned when the panel stays busy longer than the configured BusyTimeout,
+// which usually means that the panel is disconnected or broken
+var ErrBusyTimeout = errors.New("e-paper busy timeout")
+
+// PeriphProvider opens the Raspberry Pi SPI and GPIO through periph.io
+type PeriphProvider struct {
+	Config Config
+}
+
+type periphTransport struct {
+	busyTimeout time.Duration
+
+	port    spi.PortCloser
+	spiConn spi.Conn
+	pinRST  gpio.PinOut
+	pinDC   gpio.PinOut
+	pinCS   gpio.PinOut
+	pinBUSY gpio.PinIn
+}
+
+type periphOut struct {
+	pin gpio.PinOut
+}
+
+// periphPWM is a pin with the hardware PWM, it falls back to the software PWM
+// when the pin does not support it
+type periphPWM struct {
+	periphOut
+	freq physic.Frequency
+	soft *SoftPWM
+}
+
+type periphIn struct {
+	pin gpio.PinIn
+}
+
+// Open initializes the periph host and opens the SPI port and the pins
+func (pp PeriphProvider) Open() (*Hardware, error) {
+	err := pp.Config.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = host.Init()
+	if err != nil {
+		return nil, err
+	}
+
+	pins := map[string]gpio.PinIO{}
+
+	for _, pin := range pp.Config.Pins.roles() {
+		p := gpioreg.ByName(*pin.name)
+		if p == nil {
+			return nil, &PinNotFoundError{Role: pin.role, Name: *pin.name}
+		}
+
+		pins[pin.role] = p
+	}
+
+	t := &periphTransport{
+		busyTimeout: pp.Config.BusyTimeout,
+		pinRST:      pins["rst"],
+		pinDC:       pins["dc"],
+		pinCS:       pins["cs"],
+		pinBUSY:     pins["busy"],
+	}
+
+	t.port, err = spireg.Open(pp.Config.SPIDevice)
+	if err != nil {
+		return nil, err
+	}
+
+	t.spiConn, err = t.port.Connect(pp.Config.SPIClock, pp.Config.SPIMode, 8)
+	if err != nil {
+		t.port.Close()
+		return nil, err
+	}
+
+	hw := &Hardware{
+		Transport: t,
+		Fan:       &periphPWM{periphOut: periphOut{pins["fan"]}, freq: pp.Config.FanPWMFrequency},
+		Led:       &periphPWM{periphOut: periphOut{pins["led"]}, freq: ledPWMFrequency},
+	}
+
+	keys := []struct {
+		role string
+		pin  *InputPin
+	}{
+		{"ok", &hw.KeyOk},
+		{"back", &hw.KeyBack},
+		{"add", &hw.KeyAdd},
+		{"sub", &hw.KeySub},
+	}
+
+	for _, key := range keys {
+		pin := pins[key.role]
+
+		err = pin.In(gpio.PullDown, gpio.BothEdges)
+		if err != nil {
+			t.port.Close()
+			return nil, err
+		}
+
+		*key.pin = &periphIn{pin}
+	}
+
+	return hw, nil
+}
+
+func (t *periphTransport) Command(cmd byte) error {
+	return t.send(false, cmd)
+}
+
+func (t *periphTransport) Data(data ...byte) error {
+	return t.send(true, data...)
+}
+
+func (t *periphTransport) WaitBusy(level bool) error {
+	deadline := time.Now().Add(t.busyTimeout)
+
+	for t.pinBUSY.Read() == gpio.Level(level) {
+		if t.busyTimeout > 0 && time.Now().After(deadline) {
+			return ErrBusyTimeout
+		}
+
+		time.Sleep(busyPollInterval)
+	}
+
+	return nil
+}
+
+func (t *periphTransport) Reset() error {
+	for _, step := range []struct {
+		level bool
+		ms    uint
+	}{{true, 200}, {false, 10}, {true, 200}} {
+		if err := t.pinRST.Out(gpio.Level(step.level)); err != nil {
+			return err
+		}
+
+		delayms(step.ms)
+	}
+
+	return nil
+}
+
+func (t *periphTransport) Close() error {
+	// the port is closed even if the pins fail
+	_ = t.pinRST.Out(gpio.Low)
+	_ = t.pinDC.Out(gpio.Low)
+
+	return t.port.Close()
+}
+
+func (t *periphTransport) send(dc bool, b ...byte) error {
+	if err := t.pinDC.Out(gpio.Level(dc)); err != nil {
+		return err
+	}
+
+	if err := t.pinCS.Out(gpio.Low); err != nil {
+		return err
+	}
+
+	err := t.writeSPI(b...)
+
+	if csErr := t.pinCS.Out(gpio.High); err == nil {
+		err = csErr
+	}
+
+	return err
+}
+
+func (t *periphTransport) writeSPI(b ...byte) error {
+	// spidev limits the size of a single transfer, 4096 bytes by default
+	max := len(b)
+	if limits, ok := t.spiConn.(conn.Limits); ok && limits.MaxTxSize() > 0 {
+		max = limits.MaxTxSize()
+	}
+
+	for len(b) > 0 {
+		n := len(b)
+		if n > max {
+			n = max
+		}
+
+		if err := t.spiConn.Tx(b[:n], nil); err != nil {
+			return err
+		}
+
+		b = b[n:]
+	}
+
+	return nil
+}
+
+func (o *periphOut) Out(high bool) {
+	o.pin.Out(gpio.Level(high))
+}
+
+func (o *periphPWM) SetDuty(duty float64) error {
+	if duty < 0 || duty > 1 {
+		return ErrInvalidDuty
+	}
+
+	if o.soft == nil {
+		err := o.pin.PWM(gpio.Duty(duty*float64(gpio.DutyMax)), o.freq)
+		if err == nil {
+			return nil
+		}
+
+		o.soft = NewSoftPWM(&o.periphOut, softPWMFrequency)
+	}
+
+	return o.soft.SetDuty(duty)
+}
+
+func (i *periphIn) Read() bool {
+	return i.pin.Read() == gpio.High
+}
+
+func (i *periphIn) WaitForEdge(timeout time.Duration) bool {
+	return i.pin.WaitForEdge(timeout)
+}
+
+func delayms(ms uint) {
+	time.Sleep(time.Millisecond * time.Duration(ms))
+}
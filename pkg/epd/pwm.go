@@ -0,0 +1,103 @@
+package epd
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn/physic"
+)
+
+// softPWMFrequency is used when the fan pin has no hardware PWM, it is kept low for the scheduler
+const softPWMFrequency = 50 * physic.Hertz
+
+// ledPWMFrequency is the led hardware PWM frequency, high enough for the dimmed led not to flicker
+const ledPWMFrequency = 1 * physic.KiloHertz
+
+// ErrInvalidDuty is returned for a duty out of the 0-1 range
+var ErrInvalidDuty = errors.New("duty must be in 0-1 range")
+
+// DutyPin is an output pin driven with PWM
+type DutyPin interface {
+	// SetDuty sets the duty cycle 0-1, 0 is always low and 1 always high
+	SetDuty(duty float64) error
+}
+
+// SoftPWM drives an output pin with software PWM, the pin is toggled by a goroutine which only runs
+// while the duty is between 0 and 1
+type SoftPWM struct {
+	pin    OutputPin
+	period time.Duration
+
+	mu      sync.Mutex
+	duty    float64
+	running bool
+}
+
+// NewSoftPWM creates a software PWM of the pin with the freq
+func NewSoftPWM(pin OutputPin, freq physic.Frequency) *SoftPWM {
+	return &SoftPWM{
+		pin:    pin,
+		period: freq.Period(),
+	}
+}
+
+// SetDuty sets the duty cycle 0-1
+func (s *SoftPWM) SetDuty(duty float64) error {
+	if duty < 0 || duty > 1 {
+		return ErrInvalidDuty
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.duty = duty
+
+	if duty == 0 || duty == 1 {
+		// the running loop stops by itself on the next period
+		if !s.running {
+			s.pin.Out(duty == 1)
+		}
+
+		return nil
+	}
+
+	if !s.running {
+		s.running = true
+		go s.loop()
+	}
+
+	return nil
+}
+
+// Duty returns the current duty cycle
+func (s *SoftPWM) Duty() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.duty
+}
+
+func (s *SoftPWM) loop() {
+	for {
+		s.mu.Lock()
+		duty := s.duty
+
+		if duty == 0 || duty == 1 {
+			s.pin.Out(duty == 1)
+			s.running = false
+			s.mu.Unlock()
+
+			return
+		}
+
+		s.mu.Unlock()
+
+		high := time.Duration(float64(s.period) * duty)
+
+		s.pin.Out(true)
+		time.Sleep(high)
+		s.pin.Out(false)
+		time.Sleep(s.period - high)
+	}
+}
@@ -0,0 +1,359 @@
+package epd
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	SimOpInit = iota
+	SimOpClear
+	SimOpDisplay
+	SimOpSleep
+	SimOpReset
+)
+
+// SimCall is a single call recorded by the Simulator
+type SimCall struct {
+	Op      int
+	Partial bool
+	// Ignored is set when the call reached a sleeping or not connected panel and had no effect
+	Ignored bool
+	// Err is the error the call failed with, see Simulator.Fail
+	Err error
+	// Regions are the windows written by a region display, nil for the whole panel
+	Regions []image.Rectangle
+	At      time.Time
+}
+
+// Simulator is an in-memory e-paper device. It keeps a 1-bit framebuffer for the controller RAM
+// and for the visible screen, and records every call made by the Epaper.
+type Simulator struct {
+	// SnapshotPath if set, the visible screen is saved as png to this path after every refresh
+	SnapshotPath string
+
+	mu        sync.Mutex
+	panel     Panel
+	connected bool
+	asleep    bool
+	partial   bool
+	ram       []byte
+	screen    []byte
+	ramRed    []byte
+	screenRed []byte
+	calls     []SimCall
+	puCnt     int
+	err       error
+	hw        *FakeProvider
+}
+
+// NewSimulator creates a simulation of the panel
+func NewSimulator(panel Panel) *Simulator {
+	size := (panel.Width + 7) / 8 * panel.Height
+
+	return &Simulator{
+		panel:     panel,
+		ram:       make([]byte, size),
+		screen:    make([]byte, size),
+		ramRed:    filled(size, 0xff),
+		screenRed: filled(size, 0xff),
+		hw:        NewFakeProvider(),
+	}
+}
+
+// NewSimulated creates an e-paper backed by the simulator instead of the real board,
+// buttons, led and fan are wired to the simulator's fake hardware
+func NewSimulated(sim *Simulator) *Epaper {
+	b := newBoard(sim.hw)
+
+	// fake hardware never fails to open
+	_ = b.init()
+
+	return &Epaper{
+		board:  b,
+		device: sim,
+		panel:  sim.panel,
+	}
+}
+
+// Hardware returns the fake buttons, led and fan of the simulator
+func (s *Simulator) Hardware() *FakeProvider {
+	return s.hw
+}
+
+// Calls returns a copy of all recorded calls
+func (s *Simulator) Calls() []SimCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	calls := make([]SimCall, len(s.calls))
+	copy(calls, s.calls)
+
+	return calls
+}
+
+// PartialUpdates returns the number of partial refreshes since the last full refresh
+func (s *Simulator) PartialUpdates() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.puCnt
+}
+
+// Frame returns the visible screen in the panel's native orientation, the palette is black, white and red
+func (s *Simulator) Frame() *image.Paletted {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.frame()
+}
+
+// Press emulates a button press and release, btn is one of the Btn* constants
+func (s *Simulator) Press(btn int) {
+	key := s.hw.Key(btn)
+	if key == nil {
+		return
+	}
+
+	key.Set(false)
+	key.Set(true)
+}
+
+// Hold emulates holding the button down for d, it blocks until the button is released
+func (s *Simulator) Hold(btn int, d time.Duration) {
+	key := s.hw.Key(btn)
+	if key == nil {
+		return
+	}
+
+	key.Set(false)
+	time.Sleep(d)
+	key.Set(true)
+}
+
+// Fail makes all the following panel operations fail with err as a broken panel would, nil restores them
+func (s *Simulator) Fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+}
+
+func (s *Simulator) isConnected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.connected
+}
+
+func (s *Simulator) initBoard() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.connected = true
+
+	return nil
+}
+
+func (s *Simulator) init(partial bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ignored := !s.connected
+	if s.record(SimOpInit, partial, ignored) {
+		return s.err
+	}
+
+	if !ignored {
+		// both init sequences start with a hardware reset which wakes the panel up
+		s.asleep = false
+		s.partial = partial && s.panel.Partial
+	}
+
+	return nil
+}
+
+func (s *Simulator) clear(bgColor byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ignored := !s.connected || s.asleep
+	if s.record(SimOpClear, false, ignored) {
+		return s.err
+	}
+
+	if ignored {
+		return nil
+	}
+
+	for i := range s.ram {
+		s.ram[i] = bgColor
+		s.ramRed[i] = 0xff
+	}
+
+	// clearing always uses the full refresh waveform
+	s.refresh(false)
+
+	return nil
+}
+
+func (s *Simulator) display(f *frame) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ignored := !s.connected || s.asleep
+	if s.record(SimOpDisplay, s.partial, ignored) {
+		return s.err
+	}
+
+	if ignored {
+		return nil
+	}
+
+	copy(s.ram, f.black)
+
+	if f.red != nil {
+		copy(s.ramRed, f.red)
+	}
+
+	s.refresh(s.partial)
+
+	return nil
+}
+
+func (s *Simulator) displayRegions(f *frame, regions []image.Rectangle) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ignored := !s.connected || s.asleep
+	failed := s.record(SimOpDisplay, s.partial, ignored)
+	s.calls[len(s.calls)-1].Regions = regions
+
+	if failed {
+		return s.err
+	}
+
+	if ignored {
+		return nil
+	}
+
+	stride := (s.panel.Width + 7) / 8
+
+	for _, r := range regions {
+		x0, x1 := r.Min.X/8, (r.Max.X+7)/8
+
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			copy(s.ram[y*stride+x0:y*stride+x1], f.black[y*stride+x0:y*stride+x1])
+
+			if f.red != nil {
+				copy(s.ramRed[y*stride+x0:y*stride+x1], f.red[y*stride+x0:y*stride+x1])
+			}
+		}
+	}
+
+	s.refresh(s.partial)
+
+	return nil
+}
+
+func (s *Simulator) sleep() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the board is cleaned up even if the panel fails to go to sleep
+	failed := s.record(SimOpSleep, false, !s.connected)
+	s.asleep = !failed
+	s.connected = false
+
+	if failed {
+		return s.err
+	}
+
+	return nil
+}
+
+func (s *Simulator) reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.record(SimOpReset, false, false) {
+		return s.err
+	}
+
+	s.asleep = false
+
+	return nil
+}
+
+// record records the call and tells whether it failed
+func (s *Simulator) record(op int, partial bool, ignored bool) bool {
+	s.calls = append(s.calls, SimCall{
+		Op:      op,
+		Partial: partial,
+		Ignored: ignored,
+		Err:     s.err,
+		At:      time.Now(),
+	})
+
+	return s.err != nil
+}
+
+func (s *Simulator) refresh(partial bool) {
+	copy(s.screen, s.ram)
+	copy(s.screenRed, s.ramRed)
+
+	if partial {
+		s.puCnt++
+	} else {
+		s.puCnt = 0
+	}
+
+	if s.SnapshotPath == "" {
+		return
+	}
+
+	if err := s.saveSnapshot(); err != nil {
+		log.Println(err)
+	}
+}
+
+func (s *Simulator) frame() *image.Paletted {
+	palette := color.Palette{color.Black, color.White, color.RGBA{R: 0xff, A: 0xff}}
+	img := image.NewPaletted(image.Rect(0, 0, s.panel.Width, s.panel.Height), palette)
+	stride := (s.panel.Width + 7) / 8
+
+	for y := 0; y < s.panel.Height; y++ {
+		for x := 0; x < s.panel.Width; x++ {
+			idx := y*stride + x/8
+			bit := byte(1 << uint(7-x%8))
+
+			if s.screenRed[idx]&bit == 0 {
+				img.SetColorIndex(x, y, 2)
+			} else if s.screen[idx]&bit != 0 {
+				img.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+
+	return img
+}
+
+func (s *Simulator) saveSnapshot() error {
+	f, err := os.Create(s.SnapshotPath)
+	if err != nil {
+		return err
+	}
+
+	err = png.Encode(f, s.frame())
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,314 @@
+package epd
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// newSimulated returns a connected e-paper on the simulated panel
+func newSimulated(t *testing.T, name string) (*Epaper, *Simulator) {
+	t.Helper()
+
+	panel, err := LookupPanel(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sim := NewSimulator(panel)
+	paper := NewSimulated(sim)
+
+	if err := paper.InitBoard(); err != nil {
+		t.Fatal(err)
+	}
+
+	return paper, sim
+}
+
+// testImage returns a white image of the native panel size with the rect filled with the color
+func testImage(panel Panel, rect image.Rectangle, c color.Color) image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, panel.Width, panel.Height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(img, rect, image.NewUniform(c), image.Point{}, draw.Src)
+
+	return *img
+}
+
+// assertFrame checks the color index of every simulator frame pixel, rect is expected in idx and the rest white
+func assertFrame(t *testing.T, sim *Simulator, rect image.Rectangle, idx uint8) {
+	t.Helper()
+
+	frame := sim.Frame()
+
+	for y := frame.Rect.Min.Y; y < frame.Rect.Max.Y; y++ {
+		for x := frame.Rect.Min.X; x < frame.Rect.Max.X; x++ {
+			want := uint8(1)
+			if image.Pt(x, y).In(rect) {
+				want = idx
+			}
+
+			if got := frame.ColorIndexAt(x, y); got != want {
+				t.Fatalf("pixel %d,%d is %d, want %d", x, y, got, want)
+			}
+		}
+	}
+}
+
+func lastCall(t *testing.T, sim *Simulator) SimCall {
+	t.Helper()
+
+	calls := sim.Calls()
+	if len(calls) == 0 {
+		t.Fatal("no calls recorded")
+	}
+
+	return calls[len(calls)-1]
+}
+
+func TestSimulatorIgnoresCallsWhileAsleep(t *testing.T) {
+	paper, sim := newSimulated(t, "2in13v2")
+
+	if err := paper.InitFull(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := paper.Clear(0xff); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := paper.Sleep(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the simulator is driven directly as the Epaper refuses to use a disconnected board
+	if err := sim.clear(0x00); err != nil {
+		t.Fatal(err)
+	}
+
+	call := lastCall(t, sim)
+	if call.Op != SimOpClear || !call.Ignored {
+		t.Errorf("clear while asleep recorded as %+v, want an ignored clear", call)
+	}
+
+	if err := sim.display(newFrame(testImage(sim.panel, image.Rect(0, 0, 8, 8), color.Black), sim.panel, Conversion{})); err != nil {
+		t.Fatal(err)
+	}
+
+	call = lastCall(t, sim)
+	if call.Op != SimOpDisplay || !call.Ignored {
+		t.Errorf("display while asleep recorded as %+v, want an ignored display", call)
+	}
+
+	assertFrame(t, sim, image.Rectangle{}, 0)
+
+	if err := paper.Display(testImage(sim.panel, image.Rect(0, 0, 8, 8), color.Black)); err != ErrNotConnected {
+		t.Errorf("display after sleep returned %v, want %v", err, ErrNotConnected)
+	}
+}
+
+func TestSimulatorWakesUpOnInit(t *testing.T) {
+	paper, sim := newSimulated(t, "2in13v2")
+
+	if err := paper.Sleep(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := paper.InitBoard(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := paper.InitFull(); err != nil {
+		t.Fatal(err)
+	}
+
+	rect := image.Rect(8, 16, 32, 40)
+
+	if err := paper.Display(testImage(sim.panel, rect, color.Black)); err != nil {
+		t.Fatal(err)
+	}
+
+	if call := lastCall(t, sim); call.Op != SimOpDisplay || call.Ignored {
+		t.Errorf("display after init recorded as %+v, want a display", call)
+	}
+
+	assertFrame(t, sim, rect, 0)
+}
+
+func TestSimulatorCountsPartialUpdates(t *testing.T) {
+	paper, sim := newSimulated(t, "2in13v2")
+
+	if err := paper.InitFull(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := paper.Clear(0xff); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := paper.InitPartial(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		rect := image.Rect(0, i*8, 16, i*8+8)
+
+		if err := paper.Display(testImage(sim.panel, rect, color.Black)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := sim.PartialUpdates(); got != 3 {
+		t.Errorf("partial updates after 3 partial displays: %d, want 3", got)
+	}
+
+	if got := paper.PartialRefreshes(); got != 3 {
+		t.Errorf("partial refreshes of the epaper: %d, want 3", got)
+	}
+
+	// an unchanged image is not sent to the panel
+	if err := paper.Display(testImage(sim.panel, image.Rect(0, 16, 16, 24), color.Black)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := sim.PartialUpdates(); got != 3 {
+		t.Errorf("partial updates after an unchanged display: %d, want 3", got)
+	}
+
+	if err := paper.InitFull(); err != nil {
+		t.Fatal(err)
+	}
+
+	rect := image.Rect(24, 24, 48, 48)
+
+	if err := paper.Display(testImage(sim.panel, rect, color.Black)); err != nil {
+		t.Fatal(err)
+	}
+
+	if call := lastCall(t, sim); call.Partial {
+		t.Errorf("display after the full init recorded as partial")
+	}
+
+	if got := sim.PartialUpdates(); got != 0 {
+		t.Errorf("partial updates after a full display: %d, want 0", got)
+	}
+
+	assertFrame(t, sim, rect, 0)
+}
+
+func TestSimulatorWritesRegions(t *testing.T) {
+	paper, sim := newSimulated(t, "2in13v2")
+
+	if err := paper.InitFull(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := paper.Clear(0xff); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := paper.InitPartial(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first display after the init writes the whole panel as its RAM is unknown
+	first := image.Rect(0, 0, 8, 8)
+
+	if err := paper.Display(testImage(sim.panel, first, color.Black)); err != nil {
+		t.Fatal(err)
+	}
+
+	if call := lastCall(t, sim); call.Regions != nil {
+		t.Errorf("first display after the init wrote regions %v, want the whole panel", call.Regions)
+	}
+
+	rect := image.Rect(40, 100, 64, 120)
+
+	if err := paper.Display(testImage(sim.panel, rect, color.Black)); err != nil {
+		t.Fatal(err)
+	}
+
+	call := lastCall(t, sim)
+	if !call.Partial || len(call.Regions) == 0 {
+		t.Fatalf("second display recorded as %+v, want a partial region display", call)
+	}
+
+	for _, r := range call.Regions {
+		if !r.Overlaps(first) && !r.Overlaps(rect) {
+			t.Errorf("region %v does not cover a changed area", r)
+		}
+	}
+
+	assertFrame(t, sim, rect, 0)
+}
+
+func TestSimulatorRedPlane(t *testing.T) {
+	paper, sim := newSimulated(t, "2in13b")
+
+	if err := paper.InitFull(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := paper.Clear(0xff); err != nil {
+		t.Fatal(err)
+	}
+
+	assertFrame(t, sim, image.Rectangle{}, 0)
+
+	rect := image.Rect(16, 32, 48, 64)
+
+	if err := paper.Display(testImage(sim.panel, rect, color.RGBA{R: 0xff, A: 0xff})); err != nil {
+		t.Fatal(err)
+	}
+
+	assertFrame(t, sim, rect, 2)
+
+	// a black and white panel shows the red by its luminance, which is dark
+	paper, sim = newSimulated(t, "2in13v2")
+
+	if err := paper.InitFull(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := paper.Display(testImage(sim.panel, rect, color.RGBA{R: 0xff, A: 0xff})); err != nil {
+		t.Fatal(err)
+	}
+
+	assertFrame(t, sim, rect, 0)
+}
+
+func TestSimulatorFail(t *testing.T) {
+	paper, sim := newSimulated(t, "2in13v2")
+
+	if err := paper.InitFull(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := paper.Clear(0xff); err != nil {
+		t.Fatal(err)
+	}
+
+	broken := errors.New("broken")
+	sim.Fail(broken)
+
+	if err := paper.Display(testImage(sim.panel, image.Rect(0, 0, 8, 8), color.Black)); err != broken {
+		t.Errorf("display of a failing panel returned %v, want %v", err, broken)
+	}
+
+	if call := lastCall(t, sim); call.Err != broken {
+		t.Errorf("failed display recorded with error %v", call.Err)
+	}
+
+	assertFrame(t, sim, image.Rectangle{}, 0)
+
+	sim.Fail(nil)
+
+	rect := image.Rect(0, 0, 8, 8)
+
+	if err := paper.Display(testImage(sim.panel, rect, color.Black)); err != nil {
+		t.Fatal(err)
+	}
+
+	assertFrame(t, sim, rect, 0)
+}
@@ -0,0 +1,121 @@
+package epd
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden traces in testdata")
+
+// traceOps are the operations traced for every panel, the trace is recorded from after the setup
+var traceOps = []struct {
+	name  string
+	setup func(p *Epaper) error
+	op    func(p *Epaper) error
+}{
+	{
+		name: "init_full",
+		op:   (*Epaper).InitFull,
+	},
+	{
+		name: "init_partial",
+		op:   (*Epaper).InitPartial,
+	},
+	{
+		name:  "clear",
+		setup: (*Epaper).InitFull,
+		op: func(p *Epaper) error {
+			return p.Clear(0xff)
+		},
+	},
+	{
+		name:  "display",
+		setup: (*Epaper).InitFull,
+		op: func(p *Epaper) error {
+			return p.Display(testImage(p.panel, image.Rect(8, 8, 40, 40), color.Black))
+		},
+	},
+	{
+		// the panels with partial refresh write only the changed regions, the others the whole frame
+		name: "display_regions",
+		setup: func(p *Epaper) error {
+			if err := p.InitFull(); err != nil {
+				return err
+			}
+
+			if err := p.Clear(0xff); err != nil {
+				return err
+			}
+
+			if err := p.InitPartial(); err != nil {
+				return err
+			}
+
+			return p.Display(testImage(p.panel, image.Rect(8, 8, 40, 40), color.Black))
+		},
+		op: func(p *Epaper) error {
+			return p.Display(testImage(p.panel, image.Rect(16, 48, 40, 64), color.Black))
+		},
+	},
+}
+
+func TestGoldenTraces(t *testing.T) {
+	for _, name := range PanelNames() {
+		for _, op := range traceOps {
+			name, op := name, op
+
+			t.Run(name+"/"+op.name, func(t *testing.T) {
+				hw := NewFakeProvider()
+
+				paper, err := NewWithProvider(hw, Config{Panel: name})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if err := paper.InitBoard(); err != nil {
+					t.Fatal(err)
+				}
+
+				if op.setup != nil {
+					if err := op.setup(paper); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				hw.Transport.Truncate()
+
+				if err := op.op(paper); err != nil {
+					t.Fatal(err)
+				}
+
+				assertGolden(t, filepath.Join("testdata", name+"_"+op.name+".golden"), hw.Transport.String())
+			})
+		}
+	}
+}
+
+// assertGolden compares the trace with the golden file, the file is written instead with the -update flag
+func assertGolden(t *testing.T, path string, trace string) {
+	t.Helper()
+
+	if *update {
+		if err := ioutil.WriteFile(path, []byte(trace), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		return
+	}
+
+	golden, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v, run the tests with -update to create it", err)
+	}
+
+	if trace != string(golden) {
+		t.Errorf("trace differs from %s, run the tests with -update if the change is intended\ngot:\n%s", path, trace)
+	}
+}
@@ -0,0 +1,177 @@
+package fan
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Point maps the temperature in °C to the fan duty 0-1
+type Point struct {
+	Temp float64
+	Duty float64
+}
+
+// Curve is the fan duty by temperature, the duty is interpolated linearly between the points
+// and is the one of the nearest point out of their range
+type Curve []Point
+
+// CurveController sets the fan duty by the curve. The duty only goes down once the temperature drops
+// Hysteresis below the one the duty was raised at, so the fan does not flap around a point.
+type CurveController struct {
+	Curve Curve
+	// Hysteresis in °C
+	Hysteresis float64
+	// MinDuty is the lowest duty the fan keeps spinning at, lower non zero duties are raised to it
+	MinDuty float64
+	// SpinUp is how long a stopped fan is driven at full duty to get it spinning
+	SpinUp time.Duration
+
+	duty      float64
+	raisedAt  float64
+	spinUntil time.Time
+}
+
+var (
+	ErrEmptyCurve     = errors.New("fan curve has no points")
+	ErrInvalidMinDuty = errors.New("fan min duty must be in 0-1 range")
+)
+
+// DefaultCurve keeps the fan off up to 45°C and runs it at full speed from 70°C
+var DefaultCurve = Curve{
+	{Temp: 45, Duty: 0},
+	{Temp: 50, Duty: 0.4},
+	{Temp: 60, Duty: 0.7},
+	{Temp: 70, Duty: 1},
+}
+
+// NewCurveController creates a controller with the DefaultCurve, 5°C hysteresis,
+// 30% minimal duty and 2s spin up
+func NewCurveController() *CurveController {
+	return &CurveController{
+		Curve:      DefaultCurve,
+		Hysteresis: 5,
+		MinDuty:    0.3,
+		SpinUp:     2 * time.Second,
+	}
+}
+
+// ParseCurve parses the comma separated temp:percent points, e.g. "45:0,50:40,60:70,70:100"
+func ParseCurve(s string) (Curve, error) {
+	var curve Curve
+
+	for _, point := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(point), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid fan curve point %q, expected temp:percent", point)
+		}
+
+		temp, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fan curve temperature %q", parts[0])
+		}
+
+		percent, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return nil, fmt.Errorf("invalid fan curve percent %q, must be in 0-100 range", parts[1])
+		}
+
+		curve = append(curve, Point{Temp: temp, Duty: percent / 100})
+	}
+
+	sort.Slice(curve, func(i, j int) bool {
+		return curve[i].Temp < curve[j].Temp
+	})
+
+	return curve, curve.Validate()
+}
+
+// Validate checks that the curve has points with the duty in 0-1 range and no temperature twice
+func (c Curve) Validate() error {
+	if len(c) == 0 {
+		return ErrEmptyCurve
+	}
+
+	for i, point := range c {
+		if point.Duty < 0 || point.Duty > 1 {
+			return fmt.Errorf("fan curve duty %v at %v°C is out of 0-1 range", point.Duty, point.Temp)
+		}
+
+		if i > 0 && c[i-1].Temp >= point.Temp {
+			return fmt.Errorf("fan curve temperatures must be increasing, %v°C follows %v°C", point.Temp, c[i-1].Temp)
+		}
+	}
+
+	return nil
+}
+
+// Duty returns the duty 0-1 at the temp
+func (c Curve) Duty(temp float64) float64 {
+	if len(c) == 0 {
+		return 0
+	}
+
+	if temp <= c[0].Temp {
+		return c[0].Duty
+	}
+
+	for i := 1; i < len(c); i++ {
+		if temp <= c[i].Temp {
+			a, b := c[i-1], c[i]
+
+			return a.Duty + (b.Duty-a.Duty)*(temp-a.Temp)/(b.Temp-a.Temp)
+		}
+	}
+
+	return c[len(c)-1].Duty
+}
+
+// Validate checks the controller settings
+func (cc *CurveController) Validate() error {
+	if cc.MinDuty < 0 || cc.MinDuty > 1 {
+		return ErrInvalidMinDuty
+	}
+
+	if cc.Hysteresis < 0 {
+		return errors.New("fan hysteresis must not be negative")
+	}
+
+	return cc.Curve.Validate()
+}
+
+// Update returns the fan duty for the temp measured at the now time
+func (cc *CurveController) Update(temp float64, now time.Time) float64 {
+	target := cc.Curve.Duty(temp)
+
+	if target > 0 && target < cc.MinDuty {
+		target = cc.MinDuty
+	}
+
+	stopped := cc.duty == 0
+
+	if target >= cc.duty {
+		cc.duty = target
+		cc.raisedAt = temp
+	} else if temp <= cc.raisedAt-cc.Hysteresis {
+		cc.duty = target
+		cc.raisedAt = temp
+	}
+
+	if cc.duty == 0 {
+		cc.spinUntil = time.Time{}
+		return 0
+	}
+
+	if stopped && cc.SpinUp > 0 {
+		cc.spinUntil = now.Add(cc.SpinUp)
+	}
+
+	if now.Before(cc.spinUntil) {
+		return 1
+	}
+
+	return cc.duty
+}
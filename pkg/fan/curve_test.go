@@ -0,0 +1,80 @@
+package fan
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCurveDuty(t *testing.T) {
+	tests := []struct {
+		temp float64
+		duty float64
+	}{
+		{temp: 30, duty: 0},
+		{temp: 45, duty: 0},
+		{temp: 47.5, duty: 0.2},
+		{temp: 55, duty: 0.55},
+		{temp: 70, duty: 1},
+		{temp: 90, duty: 1},
+	}
+
+	for _, tt := range tests {
+		if duty := DefaultCurve.Duty(tt.temp); math.Abs(duty-tt.duty) > 1e-9 {
+			t.Errorf("duty at %v°C is %.2f, want %.2f", tt.temp, duty, tt.duty)
+		}
+	}
+}
+
+func TestCurveHysteresis(t *testing.T) {
+	cc := NewCurveController()
+	cc.SpinUp = 0
+
+	now := time.Unix(0, 0)
+
+	tests := []struct {
+		temp float64
+		duty float64
+	}{
+		{temp: 60, duty: 0.7},
+		// less than the hysteresis below the temperature the duty was raised at
+		{temp: 57, duty: 0.7},
+		{temp: 55.5, duty: 0.7},
+		{temp: 55, duty: 0.55},
+		// the duty goes up at once
+		{temp: 60, duty: 0.7},
+		{temp: 44, duty: 0},
+		// the lowest duty of a spinning fan
+		{temp: 46, duty: 0.3},
+	}
+
+	for _, tt := range tests {
+		if duty := cc.Update(tt.temp, now); math.Abs(duty-tt.duty) > 1e-9 {
+			t.Errorf("duty at %v°C is %.2f, want %.2f", tt.temp, duty, tt.duty)
+		}
+	}
+}
+
+func TestCurveSpinUp(t *testing.T) {
+	cc := NewCurveController()
+	start := time.Unix(0, 0)
+
+	if duty := cc.Update(40, start); duty != 0 {
+		t.Fatalf("duty %.2f at 40°C, want 0", duty)
+	}
+
+	tests := []struct {
+		at   time.Duration
+		duty float64
+	}{
+		{at: time.Second, duty: 1},
+		{at: 2 * time.Second, duty: 1},
+		{at: 3 * time.Second, duty: 0.4},
+	}
+
+	for _, tt := range tests {
+		if duty := cc.Update(50, start.Add(tt.at)); math.Abs(duty-tt.duty) > 1e-9 {
+			t.Errorf("duty %.2f at %s after the start, want %.2f", duty, tt.at, tt.duty)
+		}
+	}
+}
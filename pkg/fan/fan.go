@@ -0,0 +1,90 @@
+package fan
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TempSource reads the temperature in °C
+type TempSource interface {
+	Temperature() (float64, error)
+}
+
+// Output drives the fan with the duty 0-1
+type Output interface {
+	SetDuty(duty float64) error
+}
+
+// Controller decides the fan duty 0-1 for the temperature measured at the now time
+type Controller interface {
+	Update(temp float64, now time.Time) float64
+}
+
+// TempSourceFunc is a function used as TempSource
+type TempSourceFunc func() (float64, error)
+
+// OutputFunc is a function used as Output
+type OutputFunc func(duty float64) error
+
+// Temperature calls f
+func (f TempSourceFunc) Temperature() (float64, error) {
+	return f()
+}
+
+// SetDuty calls f
+func (f OutputFunc) SetDuty(duty float64) error {
+	return f(duty)
+}
+
+// Switch is a Controller whose controller is replaced while the fan runs e.g. when the fan settings change
+type Switch struct {
+	mu   sync.Mutex
+	ctrl Controller
+}
+
+// NewSwitch creates the switch starting with the ctrl
+func NewSwitch(ctrl Controller) *Switch {
+	return &Switch{ctrl: ctrl}
+}
+
+// Set replaces the controller, the next Update is done by the ctrl
+func (s *Switch) Set(ctrl Controller) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ctrl = ctrl
+}
+
+// Update calls the current controller
+func (s *Switch) Update(temp float64, now time.Time) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.ctrl.Update(temp, now)
+}
+
+// Run reads the source every interval and drives the output by the controller until the ctx is done
+// or the source or the output fails
+func Run(ctx context.Context, source TempSource, out Output, ctrl Controller, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		temp, err := source.Temperature()
+		if err != nil {
+			return err
+		}
+
+		err = out.SetDuty(ctrl.Update(temp, time.Now()))
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package fan
+
+import (
+	"errors"
+	"time"
+)
+
+// PIDController drives the fan duty to keep the temperature at the Target
+type PIDController struct {
+	// Target is the temperature in °C the controller keeps
+	Target float64
+	// Kp is the duty per °C over the target
+	Kp float64
+	// Ki is the duty per °C over the target per second
+	Ki float64
+	// Kd is the duty per °C/s of the temperature rise
+	Kd float64
+	// MinDuty is the lowest duty the fan keeps spinning at, lower non zero duties are raised to it
+	MinDuty float64
+	// MaxRate is the largest duty change per second, 0 means no limit
+	MaxRate float64
+
+	integral float64
+	prevTemp float64
+	prevAt   time.Time
+	duty     float64
+}
+
+// NewPIDController creates a controller keeping the target temperature with the gains tuned for a Raspberry Pi
+// in the NAS-Kit case
+func NewPIDController(target float64) *PIDController {
+	return &PIDController{
+		Target:  target,
+		Kp:      0.08,
+		Ki:      0.004,
+		Kd:      0.2,
+		MinDuty: 0.3,
+		MaxRate: 0.1,
+	}
+}
+
+// Validate checks the controller settings
+func (pc *PIDController) Validate() error {
+	if pc.Kp < 0 || pc.Ki < 0 || pc.Kd < 0 {
+		return errors.New("fan pid gains must not be negative")
+	}
+
+	if pc.MinDuty < 0 || pc.MinDuty > 1 {
+		return ErrInvalidMinDuty
+	}
+
+	if pc.MaxRate < 0 {
+		return errors.New("fan max duty rate must not be negative")
+	}
+
+	return nil
+}
+
+// Update returns the fan duty for the temp measured at the now time
+func (pc *PIDController) Update(temp float64, now time.Time) float64 {
+	dt := 0.0
+	if !pc.prevAt.IsZero() {
+		dt = now.Sub(pc.prevAt).Seconds()
+	}
+
+	e := temp - pc.Target
+
+	derivative := 0.0
+	if dt > 0 {
+		derivative = (temp - pc.prevTemp) / dt
+	}
+
+	// anti-windup: the integral only grows while the output is not saturated in the same direction
+	integral := pc.integral + e*dt
+	out := pc.Kp*e + pc.Ki*integral + pc.Kd*derivative
+
+	if !(out > 1 && e > 0) && !(out < 0 && e < 0) {
+		pc.integral = integral
+	}
+
+	out = pc.Kp*e + pc.Ki*pc.integral + pc.Kd*derivative
+	out = clamp(out, 0, 1)
+
+	if pc.MaxRate > 0 && dt > 0 {
+		step := pc.MaxRate * dt
+		out = clamp(out, pc.duty-step, pc.duty+step)
+	}
+
+	pc.duty = out
+	pc.prevTemp = temp
+	pc.prevAt = now
+
+	if out > 0 && out < pc.MinDuty {
+		return pc.MinDuty
+	}
+
+	return out
+}
+
+func clamp(v, min, max float64) float64 {
+	if v < min {
+		return min
+	}
+
+	if v > max {
+		return max
+	}
+
+	return v
+}
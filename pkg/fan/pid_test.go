@@ -0,0 +1,68 @@
+package fan
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPIDSettlesAtTarget(t *testing.T) {
+	samples := Simulate(NewPlant(), NewPIDController(55), time.Second, 3600)
+
+	// the last 10 minutes of the hour
+	for _, s := range samples[len(samples)-600:] {
+		if math.Abs(s.Temp-55) > 0.1 {
+			t.Fatalf("temperature %.2f°C at %s, want 55°C", s.Temp, s.At)
+		}
+	}
+}
+
+func TestPIDAntiWindup(t *testing.T) {
+	pc := NewPIDController(55)
+	pc.MaxRate = 0
+
+	start := time.Unix(0, 0)
+
+	// far over the target the duty saturates at 1 for long
+	for i := 0; i < 1000; i++ {
+		if duty := pc.Update(80, start.Add(time.Duration(i)*time.Second)); duty != 1 {
+			t.Fatalf("duty %.2f at 80°C, want 1", duty)
+		}
+	}
+
+	// the integral has not grown while saturated, so the fan slows down as soon as the temperature drops
+	pc.Update(50, start.Add(1000*time.Second))
+
+	if duty := pc.Update(50, start.Add(1001*time.Second)); duty != 0 {
+		t.Errorf("duty %.2f under the target after the saturation, want 0", duty)
+	}
+}
+
+func TestPIDMaxRate(t *testing.T) {
+	pc := NewPIDController(55)
+	pc.MinDuty = 0
+
+	start := time.Unix(0, 0)
+	pc.Update(55, start)
+
+	for i := 1; i <= 12; i++ {
+		want := math.Min(float64(i)*pc.MaxRate, 1)
+
+		if duty := pc.Update(80, start.Add(time.Duration(i)*time.Second)); math.Abs(duty-want) > 1e-9 {
+			t.Fatalf("duty %.2f after %ds over the target, want %.2f", duty, i, want)
+		}
+	}
+}
+
+func TestPIDMinDuty(t *testing.T) {
+	pc := NewPIDController(55)
+	pc.MaxRate = 0
+
+	if duty := pc.Update(56, time.Unix(0, 0)); duty != pc.MinDuty {
+		t.Errorf("duty %.2f just over the target, want the min duty %.2f", duty, pc.MinDuty)
+	}
+
+	if duty := pc.Update(50, time.Unix(1, 0)); duty != 0 {
+		t.Errorf("duty %.2f under the target, want 0", duty)
+	}
+}
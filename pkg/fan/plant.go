@@ -0,0 +1,75 @@
+package fan
+
+import (
+	"time"
+)
+
+// Plant is a simulated thermal model of a CPU cooled by the fan. It is both the TempSource
+// and the Output of a controller, so the controller can be run without the hardware.
+//
+// The temperature changes by Heat - (Loss + FanLoss*duty) * (temp - Ambient) °C per second.
+type Plant struct {
+	Temp    float64
+	Ambient float64
+	// Heat is the heating by the CPU load in °C/s
+	Heat float64
+	// Loss is the passive cooling per °C over the ambient per second
+	Loss float64
+	// FanLoss is the cooling of the fan at full duty per °C over the ambient per second
+	FanLoss float64
+	// Duty is the last duty set by the controller
+	Duty float64
+}
+
+// Sample is a single step of the simulation
+type Sample struct {
+	At   time.Duration
+	Temp float64
+	Duty float64
+}
+
+// NewPlant creates a plant resembling a loaded Raspberry Pi in the NAS-Kit case, it settles
+// at about 75°C without the fan and at about 45°C with the fan at full duty
+func NewPlant() *Plant {
+	return &Plant{
+		Temp:    40,
+		Ambient: 25,
+		Heat:    0.5,
+		Loss:    0.01,
+		FanLoss: 0.015,
+	}
+}
+
+func (p *Plant) Temperature() (float64, error) {
+	return p.Temp, nil
+}
+
+func (p *Plant) SetDuty(duty float64) error {
+	p.Duty = duty
+
+	return nil
+}
+
+// Step advances the simulation by dt
+func (p *Plant) Step(dt time.Duration) {
+	p.Temp += (p.Heat - (p.Loss+p.FanLoss*p.Duty)*(p.Temp-p.Ambient)) * dt.Seconds()
+}
+
+// Simulate runs the controller on the plant for the number of steps, the time is simulated
+// so the result is the same on every run
+func Simulate(plant *Plant, ctrl Controller, step time.Duration, steps int) []Sample {
+	samples := make([]Sample, 0, steps)
+	start := time.Unix(0, 0)
+
+	for i := 0; i < steps; i++ {
+		at := time.Duration(i) * step
+		temp, _ := plant.Temperature()
+
+		_ = plant.SetDuty(ctrl.Update(temp, start.Add(at)))
+
+		samples = append(samples, Sample{At: at, Temp: plant.Temp, Duty: plant.Duty})
+		plant.Step(step)
+	}
+
+	return samples
+}
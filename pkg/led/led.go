@@ -0,0 +1,315 @@
+package led
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// StateHealthy is set while the ui runs fine
+	StateHealthy = "healthy"
+	// StateDiskFull is set for a disk over the usage limit, as "disk-full/<path>" for every disk
+	StateDiskFull = "disk-full"
+	// StateDriverError is set while the e-paper fails
+	StateDriverError = "driver-error"
+)
+
+// tick is the update interval of the patterns changing continuously, fast enough for a smooth breathing
+const tick = 20 * time.Millisecond
+
+// Output drives the led with the duty 0-1
+type Output interface {
+	SetDuty(duty float64) error
+}
+
+// OutputFunc is a function used as Output
+type OutputFunc func(duty float64) error
+
+// Pattern is the led duty 0-1 by the time since the pattern started
+type Pattern struct {
+	Name string
+	Duty func(t time.Duration) float64
+	// Steady patterns do not change in time and are written once
+	Steady bool
+	// Next returns the time of the first duty change after t, the patterns without it are updated every tick
+	Next func(t time.Duration) time.Duration
+}
+
+// Rule plays the Pattern while the State is set, the first rule of a set state wins
+type Rule struct {
+	// State matches the state of the same name and the ones named State/anything
+	State   string
+	Pattern Pattern
+}
+
+// Controller plays the pattern of the first rule with a set state on the led
+type Controller struct {
+	out     Output
+	rules   []Rule
+	idle    Pattern
+	mu      sync.Mutex
+	states  map[string]bool
+	enabled bool
+	changed chan struct{}
+}
+
+var (
+	Off       = steady("off", 0)
+	On        = steady("on", 1)
+	SlowBlink = blink("slow", time.Second)
+	FastBlink = blink("fast", 150*time.Millisecond)
+	Heartbeat = Pattern{Name: "heartbeat", Duty: heartbeat, Next: nextHeartbeat}
+	Breathing = Pattern{Name: "breathe", Duty: breathing}
+)
+
+// DefaultRules blink fast on the e-paper failure, slowly when a disk is full and beat while all is fine
+var DefaultRules = []Rule{
+	{State: StateDriverError, Pattern: FastBlink},
+	{State: StateDiskFull, Pattern: SlowBlink},
+	{State: StateHealthy, Pattern: Heartbeat},
+}
+
+var patterns = []Pattern{Off, On, SlowBlink, FastBlink, Heartbeat, Breathing}
+
+// NewController creates an enabled controller of the led with the rules, the led is off while no rule matches
+func NewController(out Output, rules []Rule) *Controller {
+	return &Controller{
+		out:     out,
+		rules:   rules,
+		idle:    Off,
+		states:  map[string]bool{},
+		enabled: true,
+		changed: make(chan struct{}, 1),
+	}
+}
+
+// LookupPattern returns the pattern by its name: off, on, slow, fast, heartbeat or breathe
+func LookupPattern(name string) (Pattern, error) {
+	for _, pattern := range patterns {
+		if pattern.Name == name {
+			return pattern, nil
+		}
+	}
+
+	names := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		names[i] = pattern.Name
+	}
+
+	return Pattern{}, fmt.Errorf("unknown led pattern %q, use one of: %s", name, strings.Join(names, ", "))
+}
+
+// ParseRule parses a rule in the state=pattern form e.g. "disk-full=breathe"
+func ParseRule(s string) (Rule, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		return Rule{}, fmt.Errorf("invalid led rule %q, expected state=pattern", s)
+	}
+
+	pattern, err := LookupPattern(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return Rule{}, err
+	}
+
+	return Rule{State: strings.TrimSpace(parts[0]), Pattern: pattern}, nil
+}
+
+func (f OutputFunc) SetDuty(duty float64) error {
+	return f(duty)
+}
+
+// SetState sets or clears the state, the pages and background procs report the state of the system with it
+func (c *Controller) SetState(state string, on bool) {
+	c.mu.Lock()
+	if on {
+		c.states[state] = true
+	} else {
+		delete(c.states, state)
+	}
+	c.mu.Unlock()
+
+	c.notify()
+}
+
+// States returns the names of the set states sorted
+func (c *Controller) States() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	states := make([]string, 0, len(c.states))
+	for state := range c.states {
+		states = append(states, state)
+	}
+
+	sort.Strings(states)
+
+	return states
+}
+
+// SetEnabled turns the led off while it is not enabled, the states are kept
+func (c *Controller) SetEnabled(enabled bool) {
+	c.mu.Lock()
+	c.enabled = enabled
+	c.mu.Unlock()
+
+	c.notify()
+}
+
+// Enabled tells whether the led shows the patterns
+func (c *Controller) Enabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.enabled
+}
+
+// Pattern returns the pattern played now
+func (c *Controller) Pattern() Pattern {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.pattern()
+}
+
+// Run plays the patterns on the led until the ctx is done, the led is turned off then. It sleeps until the
+// next duty change of the pattern or a change of the states.
+func (c *Controller) Run(ctx context.Context) error {
+	timer := time.NewTimer(tick)
+	defer timer.Stop()
+
+	var playing string
+	var start time.Time
+	last := -1.0
+
+	for {
+		pattern := c.Pattern()
+
+		if pattern.Name != playing {
+			playing = pattern.Name
+			start = time.Now()
+			last = -1
+		}
+
+		elapsed := time.Since(start)
+
+		duty := pattern.Duty(elapsed)
+		if duty != last {
+			if err := c.out.SetDuty(duty); err != nil {
+				return err
+			}
+
+			last = duty
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+
+		// steady patterns only wait for a change of the states
+		var timerChan <-chan time.Time
+
+		switch {
+		case pattern.Steady:
+		case pattern.Next != nil:
+			timer.Reset(pattern.Next(elapsed) - elapsed)
+			timerChan = timer.C
+		default:
+			timer.Reset(tick)
+			timerChan = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			return c.out.SetDuty(0)
+		case <-c.changed:
+		case <-timerChan:
+		}
+	}
+}
+
+func (c *Controller) pattern() Pattern {
+	if !c.enabled {
+		return Off
+	}
+
+	for _, rule := range c.rules {
+		for state := range c.states {
+			if state == rule.State || strings.HasPrefix(state, rule.State+"/") {
+				return rule.Pattern
+			}
+		}
+	}
+
+	return c.idle
+}
+
+func (c *Controller) notify() {
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+}
+
+func steady(name string, duty float64) Pattern {
+	return Pattern{
+		Name:   name,
+		Duty:   func(time.Duration) float64 { return duty },
+		Steady: true,
+	}
+}
+
+func blink(name string, half time.Duration) Pattern {
+	return Pattern{
+		Name: name,
+		Duty: func(t time.Duration) float64 {
+			if (t/half)%2 == 0 {
+				return 1
+			}
+
+			return 0
+		},
+		Next: func(t time.Duration) time.Duration {
+			return (t/half + 1) * half
+		},
+	}
+}
+
+// heartbeat is two short beats every 1.2s
+func heartbeat(t time.Duration) float64 {
+	t %= 1200 * time.Millisecond
+
+	if t < 100*time.Millisecond || (t >= 250*time.Millisecond && t < 350*time.Millisecond) {
+		return 1
+	}
+
+	return 0
+}
+
+// nextHeartbeat returns the time of the first beat edge after t
+func nextHeartbeat(t time.Duration) time.Duration {
+	cycle := t / (1200 * time.Millisecond) * (1200 * time.Millisecond)
+
+	for _, edge := range []time.Duration{100, 250, 350} {
+		if at := cycle + edge*time.Millisecond; at > t {
+			return at
+		}
+	}
+
+	return cycle + 1200*time.Millisecond
+}
+
+// breathing fades the led in and out every 3s, the duty is squared for the eye to see a smooth change
+func breathing(t time.Duration) float64 {
+	phase := float64(t%(3*time.Second)) / float64(3*time.Second)
+	level := (1 - math.Cos(2*math.Pi*phase)) / 2
+
+	return math.Round(level*level*100) / 100
+}
@@ -0,0 +1,73 @@
+package led
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPatternNext(t *testing.T) {
+	for _, pattern := range []Pattern{SlowBlink, FastBlink, Heartbeat} {
+		step := time.Millisecond
+
+		for at := time.Duration(0); at < 3*time.Second; at += step {
+			next := pattern.Next(at)
+
+			if next <= at {
+				t.Fatalf("%s: next change %s is not after %s", pattern.Name, next, at)
+			}
+
+			// the duty is the same until the next change and differs at it
+			for between := at; between < next; between += step {
+				if pattern.Duty(between) != pattern.Duty(at) {
+					t.Fatalf("%s: duty changes at %s before the next change %s", pattern.Name, between, next)
+				}
+			}
+
+			if pattern.Duty(next) == pattern.Duty(next-step) {
+				t.Fatalf("%s: duty does not change at the next change %s", pattern.Name, next)
+			}
+		}
+	}
+}
+
+// countingOutput counts the duty writes
+type countingOutput struct {
+	mu     sync.Mutex
+	writes int
+}
+
+func (o *countingOutput) SetDuty(duty float64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.writes++
+
+	return nil
+}
+
+func (o *countingOutput) count() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.writes
+}
+
+func TestRunWritesOnlyTheChanges(t *testing.T) {
+	out := &countingOutput{}
+	c := NewController(out, DefaultRules)
+	c.SetState(StateHealthy, true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1100*time.Millisecond)
+	defer cancel()
+
+	if err := c.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// four beat edges in the first 1.2s and the led turned off at the end
+	if writes := out.count(); writes != 5 {
+		t.Errorf("%d duty writes of the heartbeat in 1.1s, want 5", writes)
+	}
+}
@@ -0,0 +1,251 @@
+package nasui
+
+import (
+	"fmt"
+	"nas-kit-ui/pkg/epd"
+	"strings"
+)
+
+// Action is a named action a button binding triggers
+type Action string
+
+const (
+	// ActionNone does nothing, it unbinds the buttons
+	ActionNone Action = "none"
+	// ActionNext shows the next page or selects the next menu item
+	ActionNext Action = "next"
+	// ActionPrev shows the previous page or selects the previous menu item
+	ActionPrev Action = "prev"
+	// ActionMenu opens the menu or opens the selected menu item
+	ActionMenu Action = "menu"
+	// ActionHome closes the menus and shows the first page
+	ActionHome Action = "home"
+	// ActionBack goes one menu level up, it shows the first page when no menu is open
+	ActionBack Action = "back"
+	// ActionRefresh redraws the current page now
+	ActionRefresh Action = "refresh"
+	// ActionToggleLed turns the led on or off
+	ActionToggleLed Action = "led"
+	// ActionFullRefresh redraws the current page with a full refresh to clear the ghosting
+	ActionFullRefresh Action = "full-refresh"
+)
+
+// Binding maps a button event to an action. A binding of two buttons is a chord,
+// it is triggered when the second button goes down while the first one is held.
+type Binding struct {
+	// Buttons are one or two of the epd.Btn* constants
+	Buttons []int
+	// Kind is the epd.ButtonShortPress, epd.ButtonLongPress or epd.ButtonRepeat, it is ignored for chords
+	Kind   int
+	Action Action
+}
+
+// DefaultBindings are used when NasUI has no Bindings set
+var DefaultBindings = []Binding{
+	{Buttons: []int{epd.BtnOk}, Kind: epd.ButtonShortPress, Action: ActionMenu},
+	{Buttons: []int{epd.BtnBack}, Kind: epd.ButtonShortPress, Action: ActionBack},
+	{Buttons: []int{epd.BtnAdd}, Kind: epd.ButtonShortPress, Action: ActionPrev},
+	{Buttons: []int{epd.BtnSub}, Kind: epd.ButtonShortPress, Action: ActionNext},
+	{Buttons: []int{epd.BtnAdd}, Kind: epd.ButtonRepeat, Action: ActionPrev},
+	{Buttons: []int{epd.BtnSub}, Kind: epd.ButtonRepeat, Action: ActionNext},
+	{Buttons: []int{epd.BtnOk}, Kind: epd.ButtonLongPress, Action: ActionRefresh},
+	{Buttons: []int{epd.BtnBack}, Kind: epd.ButtonLongPress, Action: ActionFullRefresh},
+	{Buttons: []int{epd.BtnOk, epd.BtnBack}, Action: ActionToggleLed},
+}
+
+var buttonNames = map[string]int{
+	"ok":   epd.BtnOk,
+	"back": epd.BtnBack,
+	"add":  epd.BtnAdd,
+	"sub":  epd.BtnSub,
+}
+
+var actions = []Action{
+	ActionNone,
+	ActionNext,
+	ActionPrev,
+	ActionMenu,
+	ActionHome,
+	ActionBack,
+	ActionRefresh,
+	ActionToggleLed,
+	ActionFullRefresh,
+}
+
+// buttonState tracks the held buttons to detect the chords
+type buttonState struct {
+	held map[int]bool
+	// chorded buttons are a part of a triggered chord, their own events are skipped until they are pressed again
+	chorded map[int]bool
+}
+
+// ParseBinding parses a binding in the trigger=action form. The trigger is a button name: ok, back, add, sub,
+// prefixed with "long:" for the long press or "repeat:" for the repeat while held, or two buttons
+// joined with "+" for a chord, e.g. "long:ok=refresh" or "add+sub=led".
+func ParseBinding(s string) (Binding, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return Binding{}, fmt.Errorf("invalid binding %q, expected trigger=action", s)
+	}
+
+	trigger, action := strings.TrimSpace(parts[0]), Action(strings.TrimSpace(parts[1]))
+
+	if !validAction(action) {
+		return Binding{}, fmt.Errorf("unknown action %q in binding %q", action, s)
+	}
+
+	binding := Binding{Kind: epd.ButtonShortPress, Action: action}
+
+	if strings.HasPrefix(trigger, "long:") {
+		binding.Kind = epd.ButtonLongPress
+		trigger = strings.TrimPrefix(trigger, "long:")
+	} else if strings.HasPrefix(trigger, "repeat:") {
+		binding.Kind = epd.ButtonRepeat
+		trigger = strings.TrimPrefix(trigger, "repeat:")
+	}
+
+	names := strings.Split(trigger, "+")
+	if len(names) > 2 {
+		return Binding{}, fmt.Errorf("invalid binding %q, a chord is two buttons", s)
+	}
+
+	for _, name := range names {
+		btn, ok := buttonNames[name]
+		if !ok {
+			return Binding{}, fmt.Errorf("unknown button %q in binding %q, use one of: ok, back, add, sub", name, s)
+		}
+
+		binding.Buttons = append(binding.Buttons, btn)
+	}
+
+	if len(binding.Buttons) == 2 {
+		if binding.Buttons[0] == binding.Buttons[1] {
+			return Binding{}, fmt.Errorf("invalid binding %q, a chord is two different buttons", s)
+		}
+
+		if binding.Kind != epd.ButtonShortPress {
+			return Binding{}, fmt.Errorf("invalid binding %q, chords can not be long pressed or repeated", s)
+		}
+	}
+
+	return binding, nil
+}
+
+// ActionNames returns the names of all the actions
+func ActionNames() []string {
+	names := make([]string, len(actions))
+
+	for i, action := range actions {
+		names[i] = string(action)
+	}
+
+	return names
+}
+
+// MergeBindings returns the bindings with the overrides applied, an override replaces
+// the binding of the same trigger and ActionNone removes it
+func MergeBindings(bindings []Binding, overrides ...Binding) []Binding {
+	merged := make([]Binding, 0, len(bindings)+len(overrides))
+	merged = append(merged, bindings...)
+
+	for _, override := range overrides {
+		for i := 0; i < len(merged); i++ {
+			if merged[i].sameTrigger(override) {
+				merged = append(merged[:i], merged[i+1:]...)
+				i--
+			}
+		}
+
+		if override.Action != ActionNone {
+			merged = append(merged, override)
+		}
+	}
+
+	return merged
+}
+
+func (b Binding) sameTrigger(other Binding) bool {
+	if len(b.Buttons) != len(other.Buttons) {
+		return false
+	}
+
+	if len(b.Buttons) == 2 {
+		return b.isChord(other.Buttons[0], other.Buttons[1])
+	}
+
+	return b.Buttons[0] == other.Buttons[0] && b.Kind == other.Kind
+}
+
+func (b Binding) isChord(first, second int) bool {
+	if len(b.Buttons) != 2 {
+		return false
+	}
+
+	return (b.Buttons[0] == first && b.Buttons[1] == second) || (b.Buttons[0] == second && b.Buttons[1] == first)
+}
+
+func validAction(action Action) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (ui *NasUI) bindings() []Binding {
+	if ui.Bindings != nil {
+		return ui.Bindings
+	}
+
+	return DefaultBindings
+}
+
+// actionForEvent returns the action bound to the button event, ActionNone if there is none
+func (ui *NasUI) actionForEvent(event epd.ButtonEvent) Action {
+	if ui.buttons.held == nil {
+		ui.buttons = buttonState{held: map[int]bool{}, chorded: map[int]bool{}}
+	}
+
+	switch event.Kind {
+	case epd.ButtonDown:
+		ui.buttons.chorded[event.Button] = false
+
+		for other, held := range ui.buttons.held {
+			if !held || other == event.Button {
+				continue
+			}
+
+			for _, binding := range ui.bindings() {
+				if binding.isChord(other, event.Button) {
+					ui.buttons.chorded[other] = true
+					ui.buttons.chorded[event.Button] = true
+					ui.buttons.held[event.Button] = true
+
+					return binding.Action
+				}
+			}
+		}
+
+		ui.buttons.held[event.Button] = true
+
+		return ActionNone
+	case epd.ButtonUp:
+		ui.buttons.held[event.Button] = false
+
+		return ActionNone
+	}
+
+	if ui.buttons.chorded[event.Button] {
+		return ActionNone
+	}
+
+	for _, binding := range ui.bindings() {
+		if len(binding.Buttons) == 1 && binding.Buttons[0] == event.Button && binding.Kind == event.Kind {
+			return binding.Action
+		}
+	}
+
+	return ActionNone
+}
@@ -0,0 +1,197 @@
+package nasui
+
+import (
+	"nas-kit-ui/pkg/epd"
+	"reflect"
+	"testing"
+)
+
+func TestParseBinding(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Binding
+		err  bool
+	}{
+		{in: "ok=menu", want: Binding{Buttons: []int{epd.BtnOk}, Kind: epd.ButtonShortPress, Action: ActionMenu}},
+		{in: " back = home ", want: Binding{Buttons: []int{epd.BtnBack}, Kind: epd.ButtonShortPress, Action: ActionHome}},
+		{in: "long:ok=refresh", want: Binding{Buttons: []int{epd.BtnOk}, Kind: epd.ButtonLongPress, Action: ActionRefresh}},
+		{in: "repeat:add=prev", want: Binding{Buttons: []int{epd.BtnAdd}, Kind: epd.ButtonRepeat, Action: ActionPrev}},
+		{in: "add+sub=led", want: Binding{Buttons: []int{epd.BtnAdd, epd.BtnSub}, Kind: epd.ButtonShortPress, Action: ActionToggleLed}},
+		{in: "sub=none", want: Binding{Buttons: []int{epd.BtnSub}, Kind: epd.ButtonShortPress, Action: ActionNone}},
+		{in: "ok", err: true},
+		{in: "ok=jump", err: true},
+		{in: "enter=menu", err: true},
+		{in: "long:=menu", err: true},
+		{in: "ok+ok=led", err: true},
+		{in: "ok+back+add=led", err: true},
+		{in: "long:ok+back=led", err: true},
+		{in: "repeat:add+sub=led", err: true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseBinding(test.in)
+
+		if test.err {
+			if err == nil {
+				t.Errorf("ParseBinding(%q) = %+v, want an error", test.in, got)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseBinding(%q): %v", test.in, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseBinding(%q) = %+v, want %+v", test.in, got, test.want)
+		}
+	}
+}
+
+func TestMergeBindings(t *testing.T) {
+	okShort := Binding{Buttons: []int{epd.BtnOk}, Kind: epd.ButtonShortPress, Action: ActionMenu}
+	okLong := Binding{Buttons: []int{epd.BtnOk}, Kind: epd.ButtonLongPress, Action: ActionRefresh}
+	chord := Binding{Buttons: []int{epd.BtnOk, epd.BtnBack}, Action: ActionToggleLed}
+	base := []Binding{okShort, okLong, chord}
+
+	tests := []struct {
+		name      string
+		overrides []Binding
+		want      []Binding
+	}{
+		{
+			name: "no overrides",
+			want: base,
+		},
+		{
+			name:      "replaced by the trigger",
+			overrides: []Binding{{Buttons: []int{epd.BtnOk}, Kind: epd.ButtonShortPress, Action: ActionHome}},
+			want:      []Binding{okLong, chord, {Buttons: []int{epd.BtnOk}, Kind: epd.ButtonShortPress, Action: ActionHome}},
+		},
+		{
+			name:      "none removes",
+			overrides: []Binding{{Buttons: []int{epd.BtnOk}, Kind: epd.ButtonLongPress, Action: ActionNone}},
+			want:      []Binding{okShort, chord},
+		},
+		{
+			name:      "chord in either order",
+			overrides: []Binding{{Buttons: []int{epd.BtnBack, epd.BtnOk}, Action: ActionNone}},
+			want:      []Binding{okShort, okLong},
+		},
+		{
+			name:      "added",
+			overrides: []Binding{{Buttons: []int{epd.BtnAdd}, Kind: epd.ButtonLongPress, Action: ActionHome}},
+			want:      []Binding{okShort, okLong, chord, {Buttons: []int{epd.BtnAdd}, Kind: epd.ButtonLongPress, Action: ActionHome}},
+		},
+		{
+			name:      "removing a missing one",
+			overrides: []Binding{{Buttons: []int{epd.BtnSub}, Kind: epd.ButtonRepeat, Action: ActionNone}},
+			want:      base,
+		},
+	}
+
+	for _, test := range tests {
+		got := MergeBindings(base, test.overrides...)
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+
+	if !reflect.DeepEqual(base, []Binding{okShort, okLong, chord}) {
+		t.Errorf("the bindings were changed: %+v", base)
+	}
+}
+
+func TestActionForEvent(t *testing.T) {
+	press := func(btn, kind int) epd.ButtonEvent {
+		return epd.ButtonEvent{Button: btn, Kind: kind}
+	}
+
+	tests := []struct {
+		name   string
+		events []epd.ButtonEvent
+		want   []Action
+	}{
+		{
+			name:   "short press",
+			events: []epd.ButtonEvent{press(epd.BtnOk, epd.ButtonDown), press(epd.BtnOk, epd.ButtonUp), press(epd.BtnOk, epd.ButtonShortPress)},
+			want:   []Action{ActionNone, ActionNone, ActionMenu},
+		},
+		{
+			name:   "long press and repeat",
+			events: []epd.ButtonEvent{press(epd.BtnAdd, epd.ButtonDown), press(epd.BtnAdd, epd.ButtonLongPress), press(epd.BtnAdd, epd.ButtonRepeat)},
+			want:   []Action{ActionNone, ActionNone, ActionPrev},
+		},
+		{
+			name: "chord skips the presses of its buttons",
+			events: []epd.ButtonEvent{
+				press(epd.BtnBack, epd.ButtonDown),
+				press(epd.BtnOk, epd.ButtonDown),
+				press(epd.BtnOk, epd.ButtonUp),
+				press(epd.BtnOk, epd.ButtonShortPress),
+				press(epd.BtnBack, epd.ButtonLongPress),
+				press(epd.BtnBack, epd.ButtonUp),
+				press(epd.BtnBack, epd.ButtonShortPress),
+			},
+			want: []Action{ActionNone, ActionToggleLed, ActionNone, ActionNone, ActionNone, ActionNone, ActionNone},
+		},
+		{
+			name: "single press after a chord",
+			events: []epd.ButtonEvent{
+				press(epd.BtnOk, epd.ButtonDown),
+				press(epd.BtnBack, epd.ButtonDown),
+				press(epd.BtnBack, epd.ButtonUp),
+				press(epd.BtnOk, epd.ButtonUp),
+				press(epd.BtnOk, epd.ButtonDown),
+				press(epd.BtnOk, epd.ButtonUp),
+				press(epd.BtnOk, epd.ButtonShortPress),
+			},
+			want: []Action{ActionNone, ActionToggleLed, ActionNone, ActionNone, ActionNone, ActionNone, ActionMenu},
+		},
+		{
+			name: "two buttons with no chord",
+			events: []epd.ButtonEvent{
+				press(epd.BtnAdd, epd.ButtonDown),
+				press(epd.BtnSub, epd.ButtonDown),
+				press(epd.BtnSub, epd.ButtonUp),
+				press(epd.BtnSub, epd.ButtonShortPress),
+			},
+			want: []Action{ActionNone, ActionNone, ActionNone, ActionNext},
+		},
+	}
+
+	for _, test := range tests {
+		ui := &NasUI{}
+
+		for i, event := range test.events {
+			if got := ui.actionForEvent(event); got != test.want[i] {
+				t.Errorf("%s: event %d got %q, want %q", test.name, i, got, test.want[i])
+			}
+		}
+	}
+}
+
+func TestActionForEventOfMergedBindings(t *testing.T) {
+	ui := &NasUI{Bindings: MergeBindings(DefaultBindings,
+		Binding{Buttons: []int{epd.BtnOk, epd.BtnBack}, Action: ActionNone},
+		Binding{Buttons: []int{epd.BtnOk}, Kind: epd.ButtonLongPress, Action: ActionHome},
+	)}
+
+	events := []epd.ButtonEvent{
+		{Button: epd.BtnOk, Kind: epd.ButtonDown},
+		{Button: epd.BtnBack, Kind: epd.ButtonDown},
+		{Button: epd.BtnBack, Kind: epd.ButtonUp},
+		{Button: epd.BtnBack, Kind: epd.ButtonShortPress},
+		{Button: epd.BtnOk, Kind: epd.ButtonLongPress},
+	}
+	want := []Action{ActionNone, ActionNone, ActionNone, ActionBack, ActionHome}
+
+	for i, event := range events {
+		if got := ui.actionForEvent(event); got != want[i] {
+			t.Errorf("event %d got %q, want %q", i, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package nasui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"time"
+)
+
+// DefaultCommandTimeout limits the commands with no Timeout
+const DefaultCommandTimeout = 30 * time.Second
+
+// Command is the action of a menu item. It runs apart from the render loop while the working screen is shown,
+// its result is shown after it, so the pages only draw. One command runs at a time.
+type Command struct {
+	// Run does the action, it is to return once the ctx is done: the Timeout passed or the ui stops
+	Run func(ctx *Context) CommandResult
+	// Title is the header of the working and the result screens, the label of the menu item is used if empty
+	Title string
+	// Working are the lines of the working screen, "Working..." if empty
+	Working []string
+	// Timeout fails the command when it does not return in time, DefaultCommandTimeout if 0
+	Timeout time.Duration
+}
+
+// CommandResult is shown when the command is done: the Page if set, the Err if it failed and the Text otherwise
+type CommandResult struct {
+	Text []string
+	Err error
+	// Page is shown instead of the Text e.g. a page of the details
+	Page *Page
+	// FullRefresh shows the Text with a full refresh, it clears the ghosting
+	FullRefresh bool
+}
+
+// task is a running command
+type task struct {
+	title string
+	started time.Time
+	// page is the working screen, the result replaces it only while it is shown
+	page *Page
+}
+
+// CommandItem is a menu item running the command
+func CommandItem(label string, command *Command) MenuItem {
+	return MenuItem{Label: label, Command: command}
+}
+
+// RunCommand runs the command showing its working screen and then its result, the running command is shown
+// again if there is one
+func (ui *NasUI) RunCommand(title string, command *Command) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.activePage = ui.startCommand(command, title)
+	ui.displayType = DisplayTypePage
+	ui.redraw = true
+	ui.notify()
+}
+
+// startCommand runs the command and returns its working screen, ui.mu is held
+func (ui *NasUI) startCommand(command *Command, title string) *Page {
+	if ui.task != nil {
+		return ui.task.page
+	}
+
+	t := ui.launchCommand(command, title)
+	ui.task = t
+
+	return t.page
+}
+
+// launchCommand runs the command apart from the running task and returns it, ui.mu is held
+func (ui *NasUI) launchCommand(command *Command, title string) *task {
+	if command.Title != "" {
+		title = command.Title
+	}
+
+	working := command.Working
+	if len(working) == 0 {
+		working = []string{"Working..."}
+	}
+
+	t := &task{title: title, started: time.Now()}
+
+	t.page = &Page{
+		Name: "Working: " + title,
+		// the elapsed time is redrawn every second
+		RefreshInterval: 1,
+		Display: func(ctx *Context) (*image.RGBA, error) {
+			return ctx.NasUI.builtinUI().WorkingPage(title, working, time.Since(t.started)), nil
+		},
+	}
+
+	timeout := command.Timeout
+	if timeout <= 0 {
+		timeout = DefaultCommandTimeout
+	}
+
+	ctx := ui.createContext()
+	runCtx, cancel := context.WithTimeout(ctx.Context, timeout)
+	ctx.Context = runCtx
+
+	go func() {
+		defer cancel()
+
+		ui.runCommand(t, ctx, command, timeout)
+	}()
+
+	return t
+}
+
+// runCommand runs the task of the command and shows its result. The command failed if it does not return
+// before the ctx is done, its result is shown at once but it stays the running task until it returns.
+func (ui *NasUI) runCommand(t *task, ctx *Context, command *Command, timeout time.Duration) {
+	done := make(chan CommandResult, 1)
+
+	go func() {
+		done <- command.Run(ctx)
+	}()
+
+	select {
+	case result := <-done:
+		ui.finishCommand(t, result)
+	case <-ctx.Done():
+		err := ctx.Err()
+		if err == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s", timeout)
+		}
+
+		ui.finishCommand(t, CommandResult{Err: err})
+		<-done
+	}
+
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	if ui.task == t {
+		ui.task = nil
+	}
+}
+
+// finishCommand shows the result of the task if its working screen is still shown, the task stays running
+// until its command returns. The failures are logged as the result may be left unseen, except the ones of
+// the commands cancelled by the stop of the ui
+func (ui *NasUI) finishCommand(t *task, result CommandResult) {
+	if result.Err != nil && !errors.Is(result.Err, context.Canceled) {
+		log.Printf("command %q: %v", t.title, result.Err)
+	}
+
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	if ui.activePage != t.page {
+		return
+	}
+
+	page := result.Page
+	if page == nil {
+		page = resultPage(t.title, result)
+	}
+
+	ui.activePage = page
+	ui.redraw = true
+	ui.notify()
+}
+
+// resultPage draws the Err or the Text of the result
+func resultPage(title string, result CommandResult) *Page {
+	page := &Page{
+		Name: "Result: " + title,
+		Display: func(ctx *Context) (*image.RGBA, error) {
+			if result.Err != nil {
+				return ctx.NasUI.builtinUI().ErrorPage(title, result.Err), nil
+			}
+
+			return ctx.NasUI.builtinUI().MenuActionTextPage(title, result.Text), nil
+		},
+	}
+
+	if result.FullRefresh {
+		page.Refresh = RefreshFull
+	}
+
+	return page
+}
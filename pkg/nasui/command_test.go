@@ -0,0 +1,150 @@
+package nasui
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitFor polls the cond until it holds, the test fails after a second
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (ui *NasUI) testActivePage() *Page {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	return ui.activePage
+}
+
+func (ui *NasUI) testCurrentPage() *Page {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	return ui.currentPage
+}
+
+func (ui *NasUI) testTask() *task {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	return ui.task
+}
+
+func TestCommandTimeoutKeepsTaskUntilRunReturns(t *testing.T) {
+	ui := &NasUI{}
+	release := make(chan struct{})
+
+	// the command does not watch its ctx
+	ui.RunCommand("Slow", &Command{
+		Timeout: 10 * time.Millisecond,
+		Run: func(ctx *Context) CommandResult {
+			<-release
+			return CommandResult{Text: []string{"slow"}}
+		},
+	})
+
+	working := ui.testActivePage()
+
+	waitFor(t, "the timeout result", func() bool {
+		return strings.HasPrefix(ui.testActivePage().Name, "Result: ")
+	})
+
+	started := make(chan struct{}, 1)
+	other := &Command{
+		Run: func(ctx *Context) CommandResult {
+			started <- struct{}{}
+			return CommandResult{}
+		},
+	}
+
+	ui.RunCommand("Other", other)
+
+	if page := ui.testActivePage(); page != working {
+		t.Errorf("the command started while the timed out one runs shows %q, want its working screen", page.Name)
+	}
+
+	select {
+	case <-started:
+		t.Fatal("a second command started while the timed out one runs")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+
+	waitFor(t, "the task end", func() bool {
+		return ui.testTask() == nil
+	})
+
+	ui.RunCommand("Other", other)
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("the command did not start after the previous one returned")
+	}
+}
+
+func TestCommandResult(t *testing.T) {
+	ui := &NasUI{}
+
+	ui.RunCommand("Quick", &Command{
+		Run: func(ctx *Context) CommandResult {
+			return CommandResult{Text: []string{"done"}}
+		},
+	})
+
+	waitFor(t, "the result", func() bool {
+		return ui.testActivePage().Name == "Result: Quick"
+	})
+
+	waitFor(t, "the task end", func() bool {
+		return ui.testTask() == nil
+	})
+}
+
+func TestBuiltinScreensWithoutDefaultUI(t *testing.T) {
+	ui, _ := newTestUI(t, "One")
+	ui.DefaultUI = nil
+	ui.Menu = nil
+
+	stop := runUI(t, ui)
+
+	// a page is current once it is drawn
+	shown := func(name string) func() bool {
+		return func() bool {
+			page := ui.testCurrentPage()
+			return page != nil && page.Name == name
+		}
+	}
+
+	waitFor(t, "the first page", shown("One"))
+
+	ui.RunCommand("Fail", &Command{
+		Run: func(ctx *Context) CommandResult {
+			return CommandResult{Err: errors.New("failed")}
+		},
+	})
+
+	waitFor(t, "the result", shown("Result: Fail"))
+
+	ui.Confirm(&Confirm{Title: "Sure", Lines: []string{"Really?"}, Timeout: time.Minute})
+
+	waitFor(t, "the dialog", shown("Confirm: Sure"))
+
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,166 @@
+package nasui
+
+import (
+	"image"
+	"time"
+)
+
+// Confirm is a Yes/No dialog. Add and Sub select the answer, Ok confirms the selected one and Back cancels
+// the dialog, the shown page before the dialog is shown again on No. It is opened by a menu item with Confirm
+// set or by NasUI.Confirm.
+type Confirm struct {
+	// Title is the dialog header, the label of the menu item is used if empty
+	Title string
+	// Lines are the question
+	Lines []string
+	// Timeout cancels the dialog when it is not answered in time, the remaining seconds are shown.
+	// 0 waits for the answer.
+	Timeout time.Duration
+	// Yes is shown when confirmed e.g. the page doing the action, a menu item shows its Page if Yes is nil
+	Yes *Page
+	// DefaultYes selects Yes when the dialog opens, No is selected otherwise
+	DefaultYes bool
+}
+
+// dialog is an open Confirm
+type dialog struct {
+	title string
+	lines []string
+	yes bool
+	deadline time.Time
+	// page draws the dialog
+	page *Page
+	yesPage *Page
+	// command is run when confirmed instead of showing the yesPage
+	command *Command
+	// backPage and backDisplayType are shown again when the dialog is cancelled
+	backPage *Page
+	backDisplayType int
+}
+
+// ConfirmItem is a menu item asking the question before it shows the page
+func ConfirmItem(label string, page *Page, lines ...string) MenuItem {
+	return MenuItem{Label: label, Page: page, Confirm: &Confirm{Lines: lines}}
+}
+
+// Confirm opens the dialog over the shown page
+func (ui *NasUI) Confirm(c *Confirm) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.activePage = ui.openDialog(c, c.Title, c.Yes, nil)
+	ui.redraw = true
+	ui.notify()
+}
+
+// openDialog opens the dialog of the confirm and returns the page drawing it, the command runs on Yes if set
+// and the yesPage is shown otherwise, ui.mu is held
+func (ui *NasUI) openDialog(c *Confirm, title string, yesPage *Page, command *Command) *Page {
+	if c.Title != "" {
+		title = c.Title
+	}
+
+	d := &dialog{
+		title: title,
+		lines: c.Lines,
+		yes: c.DefaultYes,
+		yesPage: yesPage,
+		command: command,
+		backPage: ui.activePage,
+		backDisplayType: ui.displayType,
+	}
+
+	if c.Timeout > 0 {
+		d.deadline = time.Now().Add(c.Timeout)
+	}
+
+	d.page = &Page{
+		Name: "Confirm: " + title,
+		Display: func(ctx *Context) (*image.RGBA, error) {
+			state, remaining := ctx.NasUI.dialogState(d)
+
+			return ctx.NasUI.builtinUI().ConfirmPage(state.title, state.lines, state.yes, remaining), nil
+		},
+	}
+
+	if c.Timeout > 0 {
+		// the countdown is redrawn every second
+		d.page.RefreshInterval = 1
+	}
+
+	ui.dialog = d
+
+	return d.page
+}
+
+// dialogAction returns the page the action leads to while the dialog is open, ui.mu is held
+func (ui *NasUI) dialogAction(action Action) *Page {
+	d := ui.dialog
+
+	switch action {
+	case ActionPrev, ActionNext:
+		d.yes = !d.yes
+
+		return d.page
+	case ActionMenu:
+		if d.yes && d.command != nil {
+			ui.dialog = nil
+			ui.displayType = DisplayTypePage
+
+			return ui.startCommand(d.command, d.title)
+		}
+
+		if d.yes && d.yesPage != nil {
+			ui.dialog = nil
+			ui.displayType = DisplayTypePage
+
+			return d.yesPage
+		}
+
+		return ui.closeDialog()
+	case ActionBack:
+		return ui.closeDialog()
+	case ActionHome:
+		ui.dialog = nil
+
+		return ui.pageForAction(ActionHome)
+	}
+
+	return nil
+}
+
+// closeDialog cancels the dialog and returns the page shown before it, ui.mu is held
+func (ui *NasUI) closeDialog() *Page {
+	d := ui.dialog
+	ui.dialog = nil
+	ui.displayType = d.backDisplayType
+
+	return d.backPage
+}
+
+// expireDialog cancels the dialog when its time is out, ui.mu is held
+func (ui *NasUI) expireDialog(now time.Time) {
+	if ui.dialog == nil || ui.dialog.deadline.IsZero() || now.Before(ui.dialog.deadline) {
+		return
+	}
+
+	ui.activePage = ui.closeDialog()
+	ui.redraw = true
+}
+
+// dialogState returns a copy of the dialog and its remaining time to draw it
+func (ui *NasUI) dialogState(d *dialog) (dialog, time.Duration) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	if d.deadline.IsZero() {
+		return *d, 0
+	}
+
+	remaining := time.Until(d.deadline)
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return *d, remaining
+}
@@ -0,0 +1,175 @@
+package nasui
+
+import (
+	"testing"
+	"time"
+)
+
+func (ui *NasUI) testDialog() *dialog {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	return ui.dialog
+}
+
+func TestConfirmToggle(t *testing.T) {
+	ui := &NasUI{}
+	ui.AddPages(testPages("One")...)
+	yes := &Page{Name: "Done"}
+
+	ui.Confirm(&Confirm{Title: "Sure", Yes: yes})
+
+	d := ui.testDialog()
+	if d == nil || d.yes {
+		t.Fatal("the dialog does not open on No")
+	}
+
+	for i, want := range []bool{true, false, true} {
+		action := ActionNext
+		if i%2 == 1 {
+			action = ActionPrev
+		}
+
+		ui.doAction(action)
+
+		if d.yes != want {
+			t.Fatalf("toggle %d selects yes %v, want %v", i+1, d.yes, want)
+		}
+
+		if page := ui.testActivePage(); page != d.page {
+			t.Fatalf("toggle %d shows %q, want the dialog", i+1, page.Name)
+		}
+	}
+
+	ui.doAction(ActionMenu)
+
+	if page := ui.testActivePage(); page != yes {
+		t.Errorf("yes shows %q, want its page", page.Name)
+	}
+
+	if ui.testDialog() != nil {
+		t.Error("the dialog stays open after yes")
+	}
+}
+
+func TestConfirmCancel(t *testing.T) {
+	tests := []struct {
+		name       string
+		defaultYes bool
+		actions    []Action
+	}{
+		{"no", false, []Action{ActionMenu}},
+		{"back", true, []Action{ActionBack}},
+		{"toggled to no", true, []Action{ActionNext, ActionMenu}},
+	}
+
+	for _, test := range tests {
+		ui := &NasUI{}
+		ui.AddPages(testPages("One", "Two")...)
+		ui.doAction(ActionNext)
+
+		back := ui.testActivePage()
+
+		ui.Confirm(&Confirm{Title: "Sure", Yes: &Page{Name: "Done"}, DefaultYes: test.defaultYes})
+
+		for _, action := range test.actions {
+			ui.doAction(action)
+		}
+
+		if page := ui.testActivePage(); page != back {
+			t.Errorf("%s: shows %q, want the page before the dialog", test.name, page.Name)
+		}
+
+		if ui.testDialog() != nil {
+			t.Errorf("%s: the dialog stays open", test.name)
+		}
+	}
+}
+
+func TestConfirmYesRunsCommand(t *testing.T) {
+	ui := &NasUI{}
+	ui.AddPages(testPages("One")...)
+	ran := make(chan struct{}, 1)
+
+	ui.mu.Lock()
+	ui.activePage = ui.openDialog(&Confirm{DefaultYes: true}, "Reboot", nil, &Command{
+		Run: func(ctx *Context) CommandResult {
+			ran <- struct{}{}
+			return CommandResult{}
+		},
+	})
+	ui.mu.Unlock()
+
+	ui.doAction(ActionMenu)
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("the command did not run on yes")
+	}
+
+	waitFor(t, "the result", func() bool {
+		return ui.testActivePage().Name == "Result: Reboot"
+	})
+}
+
+func TestExpireDialog(t *testing.T) {
+	ui := &NasUI{}
+	ui.AddPages(testPages("One")...)
+	ui.doAction(ActionHome)
+
+	back := ui.testActivePage()
+
+	ui.Confirm(&Confirm{Title: "Sure", Timeout: 20 * time.Millisecond})
+
+	d := ui.testDialog()
+	if d.page.RefreshInterval != 1 {
+		t.Errorf("the countdown is redrawn every %gs, want every second", d.page.RefreshInterval)
+	}
+
+	if _, remaining := ui.dialogState(d); remaining <= 0 || remaining > 20*time.Millisecond {
+		t.Errorf("remaining %s, want up to the timeout", remaining)
+	}
+
+	ui.mu.Lock()
+	ui.expireDialog(time.Now())
+	ui.mu.Unlock()
+
+	if ui.testDialog() != d {
+		t.Fatal("the dialog expired before its timeout")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if _, remaining := ui.dialogState(d); remaining != 0 {
+		t.Errorf("remaining %s after the timeout, want 0", remaining)
+	}
+
+	ui.mu.Lock()
+	ui.expireDialog(time.Now())
+	redraw := ui.redraw
+	ui.mu.Unlock()
+
+	if ui.testDialog() != nil {
+		t.Fatal("the dialog is open after its timeout")
+	}
+
+	if page := ui.testActivePage(); page != back || !redraw {
+		t.Errorf("shows %q redraw %v after the timeout, want the page before the dialog redrawn", page.Name, redraw)
+	}
+}
+
+func TestExpireDialogWithoutTimeout(t *testing.T) {
+	ui := &NasUI{}
+	ui.AddPages(testPages("One")...)
+
+	ui.Confirm(&Confirm{Title: "Sure"})
+
+	ui.mu.Lock()
+	ui.expireDialog(time.Now().Add(time.Hour))
+	ui.mu.Unlock()
+
+	if ui.testDialog() == nil {
+		t.Error("the dialog with no timeout expired")
+	}
+}
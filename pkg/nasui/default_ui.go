@@ -11,10 +11,15 @@ import (
 	"image/png"
 	"log"
 	"math"
+	"nas-kit-ui/pkg/epd"
 	"strings"
+	"time"
 )
 
 type DefaultUI struct {
+	// AccentColor is used for the gauges over the AccentThreshold percent, e.g. red on three-color panels
+	AccentColor color.Color
+	AccentThreshold float64
 	width int
 	height int
 	font string
@@ -38,13 +43,18 @@ type UsageInfo struct {
 
 const maxMenuItemsPerPage = 3
 
-func NewDefaultUI(orientation int, font string) *DefaultUI  {
-	width := DisplayWidth
-	height := DisplayHeight
+const maxErrorLines = 4
+
+// maxConfirmLines fit above the Yes and No buttons of the ConfirmPage
+const maxConfirmLines = 2
+
+// NewDefaultUI creates the default UI with the canvas size of the panel,
+// OrientationVertical puts the long side of the panel horizontally
+func NewDefaultUI(panel epd.Panel, orientation int, font string) *DefaultUI  {
+	width, height := panel.Landscape()
 
 	if orientation == OrientationHorizontal {
-		width = DisplayHeight
-		height = DisplayWidth
+		width, height = height, width
 	}
 
 	defaultUi := &DefaultUI{
@@ -96,7 +106,184 @@ func (de *DefaultUI) MenuActionTextPage(label string, lines []string) *image.RGB
 	return dest
 }
 
+// ConfirmPage shows the question with the Yes and No buttons, the selected one is filled. The remaining
+// seconds are shown in the header unless remaining is 0.
+func (de *DefaultUI) ConfirmPage(label string, lines []string, yes bool, remaining time.Duration) *image.RGBA {
+	if len(lines) > maxConfirmLines {
+		lines = lines[:maxConfirmLines]
+	}
+
+	dest := de.MenuActionTextPage(label, lines)
+	gc := draw2dimg.NewGraphicContext(dest)
+
+	gc.SetFontSize(14)
+	gc.SetFontData(draw2d.FontData{
+		Name: de.font,
+	})
+
+	if remaining > 0 {
+		de.drawHeaderNote(gc, fmt.Sprintf("%ds", int(math.Ceil(remaining.Seconds()))))
+	}
+
+	buttonWidth := float64(de.width - 24) / 2
+	buttonHeight := 26.0
+	top := float64(de.height) - buttonHeight - 4
+
+	for i, answer := range []string{"Yes", "No"} {
+		x := 8 + float64(i) * (buttonWidth + 8)
+		selected := (answer == "Yes") == yes
+
+		gc.SetFillColor(image.Black)
+		drawRect(gc, x, top, buttonWidth, buttonHeight)
+		gc.Fill()
+
+		textColor := color.Color(image.White)
+
+		if !selected {
+			gc.SetFillColor(image.White)
+			drawRect(gc, x + 2, top + 2, buttonWidth - 4, buttonHeight - 4)
+			gc.Fill()
+
+			textColor = image.Black
+		}
+
+		left, _, right, _ := gc.GetStringBounds(answer)
+
+		gc.SetFillColor(textColor)
+		gc.FillStringAt(answer, x + (buttonWidth - (right - left)) / 2, top + 19)
+	}
+
+	return dest
+}
+
+// WorkingPage shows the lines of a running command with the elapsed seconds in the header
+func (de *DefaultUI) WorkingPage(label string, lines []string, elapsed time.Duration) *image.RGBA {
+	dest := de.MenuActionTextPage(label, lines)
+	gc := draw2dimg.NewGraphicContext(dest)
+
+	gc.SetFontSize(14)
+	gc.SetFontData(draw2d.FontData{
+		Name: de.font,
+	})
+
+	de.drawHeaderNote(gc, fmt.Sprintf("%ds", int(elapsed.Seconds())))
+
+	return dest
+}
+
+// EditorPage shows the edited value in a frame or the choices with the selected one framed
+func (de *DefaultUI) EditorPage(label string, view EditorView) *image.RGBA {
+	dest := de.MenuActionTextPage(label, nil)
+	gc := draw2dimg.NewGraphicContext(dest)
+
+	gc.SetFontSize(14)
+	gc.SetFontData(draw2d.FontData{
+		Name: de.font,
+	})
+
+	if len(view.Choices) > 0 {
+		de.drawChoices(gc, view.Choices, view.Selected)
+
+		return dest
+	}
+
+	top := float64(de.height) / 2 - 18
+
+	gc.SetFillColor(image.Black)
+	drawRect(gc, 8, top, float64(de.width - 16), 36)
+	gc.Fill()
+
+	gc.SetFillColor(image.White)
+	drawRect(gc, 10, top + 2, float64(de.width - 20), 32)
+	gc.Fill()
+
+	gc.SetFontSize(18)
+	left, _, right, _ := gc.GetStringBounds(view.Value)
+
+	gc.SetFillColor(image.Black)
+	gc.FillStringAt(view.Value, (float64(de.width) - (right - left)) / 2, top + 26)
+
+	gc.SetFontSize(10)
+	gc.FillStringAt("add/sub: change, ok: save", 8, float64(de.height) - 6)
+
+	return dest
+}
+
+// drawChoices lists the choices page by page as the menu items, the page of the selected one is drawn
+func (de *DefaultUI) drawChoices(gc *draw2dimg.GraphicContext, choices []string, selected int) {
+	pageN := selected / maxMenuItemsPerPage
+	totalPages := int(math.Ceil(float64(len(choices)) / float64(maxMenuItemsPerPage)))
+
+	de.drawHeaderNote(gc, fmt.Sprintf("%d/%d", pageN + 1, totalPages))
+
+	for n := 0; n < maxMenuItemsPerPage; n++ {
+		idx := pageN * maxMenuItemsPerPage + n
+		if idx >= len(choices) {
+			break
+		}
+
+		top := float64(24 + n * 30)
+
+		if idx == selected {
+			gc.SetFillColor(image.Black)
+			drawRect(gc, 8, top, float64(de.width - 16), 28)
+			gc.Fill()
+
+			gc.SetFillColor(image.White)
+			drawRect(gc, 10, top + 2, float64(de.width - 20), 24)
+			gc.Fill()
+		}
+
+		gc.SetFillColor(image.Black)
+		gc.FillStringAt(choices[idx], 14, top + 19)
+	}
+}
+
+// drawHeaderNote draws the note at the right of the header over the label
+func (de *DefaultUI) drawHeaderNote(gc *draw2dimg.GraphicContext, note string) {
+	left, _, right, _ := gc.GetStringBounds(note)
+
+	gc.SetFillColor(image.White)
+	drawRect(gc, float64(de.width) - (right - left) - 8, 0, (right - left) + 8, 17)
+	gc.Fill()
+
+	gc.SetFillColor(image.Black)
+	gc.FillStringAt(note, float64(de.width) - (right - left), 16)
+}
+
+// breadcrumbs joins the labels of the open menus, the first ones are left out while it is wider than the width
+func breadcrumbs(gc *draw2dimg.GraphicContext, labels []string, width float64) string {
+	s := ""
+
+	for i := range labels {
+		s = strings.Join(labels[i:], ">")
+		if i > 0 {
+			s = "<" + s
+		}
+
+		left, _, right, _ := gc.GetStringBounds(s)
+		if right - left <= width {
+			break
+		}
+	}
+
+	return s
+}
+
+// ErrorPage shows the error of the page, the error message is split into lines at the colons
+func (de *DefaultUI) ErrorPage(pageName string, err error) *image.RGBA {
+	lines := strings.Split(err.Error(), ": ")
+
+	if len(lines) > maxErrorLines {
+		lines = lines[:maxErrorLines]
+	}
+
+	return de.MenuActionTextPage(fmt.Sprintf("Error: %s", pageName), lines)
+}
+
 func (de *DefaultUI) MenuPage(ctx *Context) (*image.RGBA, error) {
+	menu, labels := ctx.NasUI.menuState()
+
 	dest := image.NewRGBA(image.Rect(0, 0, de.width, de.height)) // horizontal
 	gc := draw2dimg.NewGraphicContext(dest)
 
@@ -111,24 +298,24 @@ func (de *DefaultUI) MenuPage(ctx *Context) (*image.RGBA, error) {
 		Name: de.font,
 	})
 
-	itemsPerPage := ctx.NasUI.Menu.PerPage
+	itemsPerPage := menu.PerPage
 
 	if itemsPerPage > maxMenuItemsPerPage || itemsPerPage <= 0 {
 		itemsPerPage = maxMenuItemsPerPage
 	}
 
 	row := 16.0
-	totalPages := int(math.Ceil(float64(len(ctx.NasUI.Menu.MenuItems)) / float64(itemsPerPage)))
+	totalPages := int(math.Ceil(float64(len(menu.MenuItems)) / float64(itemsPerPage)))
 	pageN := 1
 	offset := 0
 	space := 2
 
-	if len(ctx.NasUI.Menu.MenuItems) > itemsPerPage && ctx.NasUI.Menu.ItemIndex > itemsPerPage - 1 {
-		pageN = int(math.Ceil(float64(ctx.NasUI.Menu.ItemIndex + 1) / float64(itemsPerPage)))
+	if len(menu.MenuItems) > itemsPerPage && menu.ItemIndex > itemsPerPage - 1 {
+		pageN = int(math.Ceil(float64(menu.ItemIndex + 1) / float64(itemsPerPage)))
 		offset = itemsPerPage * (pageN - 1)
 	}
 
-	gc.FillStringAt(ctx.NasUI.Menu.Label, 0, row)
+	gc.FillStringAt(breadcrumbs(gc, labels, float64(de.width - 48)), 0, row)
 	gc.FillStringAt(fmt.Sprintf("%d/%d", pageN, totalPages), float64(de.width - 44), row)
 
 
@@ -139,13 +326,13 @@ func (de *DefaultUI) MenuPage(ctx *Context) (*image.RGBA, error) {
 	for n := 1; n <= itemsPerPage; n++ {
 
 		idx := n - 1 + offset
-		if idx > len(ctx.NasUI.Menu.MenuItems) - 1 {
+		if idx > len(menu.MenuItems) - 1 {
 			break
 		}
 
-		menuItem := ctx.NasUI.Menu.MenuItems[idx]
+		menuItem := menu.MenuItems[idx]
 
-		if idx == ctx.NasUI.Menu.ItemIndex {
+		if idx == menu.ItemIndex {
 			gc.SetFillColor(image.Black)
 			drawRect(gc, 8, float64(n * 28 + space * n), float64(de.width-16), 28)
 			gc.Fill()
@@ -158,7 +345,12 @@ func (de *DefaultUI) MenuPage(ctx *Context) (*image.RGBA, error) {
 		}
 
 		gc.SetFontSize(14)
-		gc.FillStringAt(fmt.Sprintf("%d.%s", idx + 1, menuItem.Label), 14, float64(n * 30 + 19))
+		label := menuItem.Label
+		if menuItem.Submenu != nil {
+			label += " >"
+		}
+
+		gc.FillStringAt(fmt.Sprintf("%d.%s", idx + 1, label), 14, float64(n * 30 + 19))
 	}
 
 	return dest, nil
@@ -197,7 +389,7 @@ func (de *DefaultUI) DiscInfoOneDisc(label string, bgLabel string, di *DiskInfo)
 		gaugeLength = 3
 	}
 
-	gc.SetFillColor(image.Black)
+	gc.SetFillColor(de.gaugeColor(di.UsedPercent))
 	drawRect(gc, 3, 57, gaugeLength - 3, 12)
 	gc.Fill()
 	// eo gauge
@@ -260,7 +452,7 @@ func (de *DefaultUI) DiscInfoTwoDiscs(label string, bgLabel string, dis []*DiskI
 			gaugeLength = 3
 		}
 
-		gc.SetFillColor(image.Black)
+		gc.SetFillColor(de.gaugeColor(di.UsedPercent))
 		drawRect(gc, 3, 42 + panHeight, gaugeLength - 3, 8)
 		gc.Fill()
 		// eo gauge
@@ -351,6 +543,14 @@ func (de *DefaultUI) AddPageHeader(gc *draw2dimg.GraphicContext, label string, b
 	gc.Fill()
 }
 
+func (de *DefaultUI) gaugeColor(percent float64) color.Color {
+	if de.AccentColor != nil && de.AccentThreshold > 0 && percent >= de.AccentThreshold {
+		return de.AccentColor
+	}
+
+	return image.Black
+}
+
 func drawRect(gc *draw2dimg.GraphicContext, x, y, w, h float64) {
 	gc.BeginPath()
 	gc.MoveTo(x, y)
@@ -0,0 +1,254 @@
+package nasui
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+)
+
+// Editor edits a setting on the editor page of a menu item. Add steps the value up and Sub down, Ok commits
+// the value and Back leaves it unchanged going back to the menu.
+type Editor interface {
+	// Begin starts editing the current value of the setting
+	Begin()
+	// Change steps the edited value, up when steps is positive
+	Change(steps int)
+	// View returns what the editor page draws
+	View() EditorView
+	// Commit returns the setting of the edited value, it runs as a Command apart from the render loop
+	Commit() func() error
+}
+
+// EditorView is the edited value, the Choices with the Selected one if there are any
+type EditorView struct {
+	Value string
+	Choices []string
+	Selected int
+}
+
+// NumberEditor is a spinner of the number from Min to Max by Step
+type NumberEditor struct {
+	Min float64
+	Max float64
+	Step float64
+	// Format prints the number e.g. "%.0f°C", "%g" if empty
+	Format string
+	Get func() float64
+	Set func(value float64) error
+	value float64
+}
+
+// ToggleEditor switches the setting on and off
+type ToggleEditor struct {
+	// On and Off are the shown states, "On" and "Off" if empty
+	On string
+	Off string
+	Get func() bool
+	Set func(value bool) error
+	value bool
+}
+
+// ChoiceEditor selects one of the Choices
+type ChoiceEditor struct {
+	Choices []string
+	Get func() string
+	Set func(value string) error
+	selected int
+}
+
+// editing is the open editor
+type editing struct {
+	label string
+	editor Editor
+	// page draws the editor
+	page *Page
+}
+
+// EditorItem is a menu item editing the setting on its editor page
+func EditorItem(label string, editor Editor) MenuItem {
+	return MenuItem{Label: label, Editor: editor}
+}
+
+func (e *NumberEditor) Begin() {
+	e.value = math.Max(e.Min, math.Min(e.Max, e.Get()))
+}
+
+func (e *NumberEditor) Change(steps int) {
+	value := e.value + float64(steps) * e.Step
+
+	if e.Step > 0 {
+		// the value is kept on the steps from Min so the float errors do not add up, the error of the
+		// step itself is rounded off e.g. 3 steps of 0.1 are 0.3
+		value = e.Min + math.Round((value - e.Min) / e.Step) * e.Step
+		value, _ = strconv.ParseFloat(strconv.FormatFloat(value, 'g', 12, 64), 64)
+	}
+
+	e.value = math.Max(e.Min, math.Min(e.Max, value))
+}
+
+func (e *NumberEditor) View() EditorView {
+	format := e.Format
+	if format == "" {
+		format = "%g"
+	}
+
+	return EditorView{Value: fmt.Sprintf(format, e.value)}
+}
+
+func (e *NumberEditor) Commit() func() error {
+	value := e.value
+
+	return func() error {
+		return e.Set(value)
+	}
+}
+
+func (e *ToggleEditor) Begin() {
+	e.value = e.Get()
+}
+
+func (e *ToggleEditor) Change(steps int) {
+	if steps % 2 != 0 {
+		e.value = !e.value
+	}
+}
+
+func (e *ToggleEditor) View() EditorView {
+	on, off := e.On, e.Off
+	if on == "" {
+		on = "On"
+	}
+
+	if off == "" {
+		off = "Off"
+	}
+
+	if e.value {
+		return EditorView{Value: on}
+	}
+
+	return EditorView{Value: off}
+}
+
+func (e *ToggleEditor) Commit() func() error {
+	value := e.value
+
+	return func() error {
+		return e.Set(value)
+	}
+}
+
+func (e *ChoiceEditor) Begin() {
+	e.selected = 0
+
+	current := e.Get()
+	for idx, choice := range e.Choices {
+		if choice == current {
+			e.selected = idx
+		}
+	}
+}
+
+// Change moves the selection, up is the choice above
+func (e *ChoiceEditor) Change(steps int) {
+	if len(e.Choices) == 0 {
+		return
+	}
+
+	e.selected = ((e.selected - steps) % len(e.Choices) + len(e.Choices)) % len(e.Choices)
+}
+
+func (e *ChoiceEditor) View() EditorView {
+	if len(e.Choices) == 0 {
+		return EditorView{}
+	}
+
+	return EditorView{Value: e.Choices[e.selected], Choices: e.Choices, Selected: e.selected}
+}
+
+func (e *ChoiceEditor) Commit() func() error {
+	if len(e.Choices) == 0 {
+		return func() error {
+			return nil
+		}
+	}
+
+	value := e.Choices[e.selected]
+
+	return func() error {
+		return e.Set(value)
+	}
+}
+
+// openEditor begins the editing and returns the editor page, ui.mu is held
+func (ui *NasUI) openEditor(editor Editor, label string) *Page {
+	editor.Begin()
+
+	ed := &editing{label: label, editor: editor}
+
+	ed.page = &Page{
+		Name: "Edit: " + label,
+		Display: func(ctx *Context) (*image.RGBA, error) {
+			return ctx.NasUI.builtinUI().EditorPage(label, ctx.NasUI.editorView(ed)), nil
+		},
+	}
+
+	ui.editing = ed
+	ui.displayType = DisplayTypePage
+
+	return ed.page
+}
+
+// editorAction returns the page the action leads to while the editor is open, ui.mu is held
+func (ui *NasUI) editorAction(action Action) *Page {
+	ed := ui.editing
+
+	switch action {
+	case ActionPrev:
+		ed.editor.Change(1)
+
+		return ed.page
+	case ActionNext:
+		ed.editor.Change(-1)
+
+		return ed.page
+	case ActionMenu:
+		ui.editing = nil
+
+		value := ed.editor.View().Value
+		commit := ed.editor.Commit()
+
+		// the setting is saved even while a command runs, the edit would be lost otherwise
+		return ui.launchCommand(&Command{
+			Working: []string{"Saving..."},
+			Run: func(ctx *Context) CommandResult {
+				err := commit()
+				if err != nil {
+					return CommandResult{Err: err}
+				}
+
+				return CommandResult{Text: []string{"Saved: " + value}}
+			},
+		}, ed.label).page
+	case ActionBack:
+		ui.editing = nil
+		ui.displayType = DisplayTypeMenu
+
+		return ui.menuPage()
+	case ActionHome:
+		ui.editing = nil
+
+		return ui.pageForAction(ActionHome)
+	}
+
+	return nil
+}
+
+// editorView returns the view of the editor to draw it while the navigation changes it
+func (ui *NasUI) editorView(ed *editing) EditorView {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	return ed.editor.View()
+}
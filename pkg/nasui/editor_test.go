@@ -0,0 +1,174 @@
+package nasui
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEditorSavesWhileCommandRuns(t *testing.T) {
+	ui := &NasUI{}
+	release := make(chan struct{})
+	defer close(release)
+
+	ui.RunCommand("Slow", &Command{
+		Run: func(ctx *Context) CommandResult {
+			<-release
+			return CommandResult{}
+		},
+	})
+
+	saved := make(chan float64, 1)
+	editor := &NumberEditor{Min: 0, Max: 10, Step: 1, Get: func() float64 { return 5 }, Set: func(v float64) error {
+		saved <- v
+		return nil
+	}}
+
+	ui.mu.Lock()
+	ui.activePage = ui.openEditor(editor, "Number")
+	ui.editorAction(ActionPrev)
+	ui.activePage = ui.editorAction(ActionMenu)
+	ui.mu.Unlock()
+
+	select {
+	case v := <-saved:
+		if v != 6 {
+			t.Errorf("saved %v, want 6", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the setting was not saved while a command runs")
+	}
+
+	waitFor(t, "the saved result", func() bool {
+		return ui.testActivePage().Name == "Result: Number"
+	})
+}
+
+func TestNumberEditor(t *testing.T) {
+	tests := []struct {
+		name    string
+		editor  NumberEditor
+		current float64
+		steps   []int
+		want    string
+	}{
+		{"step up", NumberEditor{Min: 0, Max: 10, Step: 1}, 5, []int{1}, "6"},
+		{"step down", NumberEditor{Min: 0, Max: 10, Step: 1}, 5, []int{-2}, "3"},
+		{"rounded to the steps from min", NumberEditor{Min: 0.5, Max: 10, Step: 0.5}, 1.3, []int{1}, "2"},
+		{"no float error", NumberEditor{Min: 0, Max: 1, Step: 0.1}, 0, []int{1, 1, 1}, "0.3"},
+		{"clamped to max", NumberEditor{Min: 0, Max: 10, Step: 3}, 9, []int{1}, "10"},
+		{"clamped to min", NumberEditor{Min: 2, Max: 10, Step: 1}, 3, []int{-5}, "2"},
+		{"current over max", NumberEditor{Min: 0, Max: 10, Step: 1}, 50, nil, "10"},
+		{"current under min", NumberEditor{Min: 30, Max: 80, Step: 1}, 0, nil, "30"},
+		{"format", NumberEditor{Min: 30, Max: 80, Step: 1, Format: "%.0f°C"}, 55, []int{1}, "56°C"},
+	}
+
+	for _, test := range tests {
+		editor := test.editor
+		current := test.current
+		editor.Get = func() float64 { return current }
+
+		editor.Begin()
+
+		for _, steps := range test.steps {
+			editor.Change(steps)
+		}
+
+		if got := editor.View().Value; got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestChoiceEditorWraps(t *testing.T) {
+	selected := "a"
+	editor := &ChoiceEditor{Choices: []string{"a", "b", "c"}, Get: func() string { return selected }}
+
+	editor.Begin()
+
+	// up is the choice above, so it wraps from the first choice to the last one
+	for i, step := range []struct {
+		steps int
+		want  string
+	}{
+		{1, "c"},
+		{-1, "a"},
+		{-1, "b"},
+		{-2, "a"},
+		{4, "c"},
+	} {
+		editor.Change(step.steps)
+
+		if view := editor.View(); view.Value != step.want || view.Choices[view.Selected] != step.want {
+			t.Fatalf("change %d selects %+v, want %s", i+1, view, step.want)
+		}
+	}
+
+	selected = "unknown"
+	editor.Begin()
+
+	if got := editor.View().Value; got != "a" {
+		t.Errorf("an unknown current value selects %s, want the first choice", got)
+	}
+}
+
+func TestToggleEditor(t *testing.T) {
+	editor := &ToggleEditor{On: "Yes", Get: func() bool { return true }}
+
+	editor.Begin()
+	editor.Change(2)
+
+	if got := editor.View().Value; got != "Yes" {
+		t.Errorf("two steps show %s, want Yes", got)
+	}
+
+	editor.Change(-1)
+
+	if got := editor.View().Value; got != "Off" {
+		t.Errorf("one step shows %s, want Off", got)
+	}
+}
+
+func TestEditorBackLeavesValue(t *testing.T) {
+	value := 5.0
+	sets := 0
+
+	ui := &NasUI{}
+	ui.AddPages(testPages("One")...)
+	ui.Menu = NewMenu("Menu", &Page{Name: "Menu page"},
+		EditorItem("Number", &NumberEditor{Min: 0, Max: 10, Step: 1, Get: func() float64 { return value }, Set: func(v float64) error {
+			sets++
+			value = v
+			return nil
+		}}),
+	)
+
+	ui.doAction(ActionMenu)
+	ui.doAction(ActionMenu)
+
+	if page := ui.testActivePage(); page.Name != "Edit: Number" {
+		t.Fatalf("shows %q, want the editor", page.Name)
+	}
+
+	ui.doAction(ActionPrev)
+	ui.doAction(ActionPrev)
+	ui.doAction(ActionBack)
+
+	if page := ui.testActivePage(); page != ui.Menu.Page {
+		t.Errorf("back shows %q, want the menu", page.Name)
+	}
+
+	if sets != 0 || value != 5 {
+		t.Errorf("back set the value %d times to %g, want it unchanged", sets, value)
+	}
+
+	// the editor starts again from the unchanged value
+	ui.doAction(ActionMenu)
+
+	ui.mu.Lock()
+	view := ui.editing.editor.View()
+	ui.mu.Unlock()
+
+	if view.Value != "5" {
+		t.Errorf("the reopened editor shows %s, want 5", view.Value)
+	}
+}
@@ -0,0 +1,94 @@
+package nasui
+
+// NewMenu creates the menu of the items, the page draws it and its submenus
+func NewMenu(label string, page *Page, items ...MenuItem) *Menu {
+	return &Menu{
+		Label: label,
+		Page: page,
+		MenuItems: items,
+	}
+}
+
+// PageItem is a menu item showing the page
+func PageItem(label string, page *Page) MenuItem {
+	return MenuItem{Label: label, Page: page}
+}
+
+// SubmenuItem is a menu item opening the submenu of the items, it is drawn by the page of its parent menu
+// e.g. SubmenuItem("System", SubmenuItem("Power", PageItem("Reboot", rebootPage)))
+func SubmenuItem(label string, items ...MenuItem) MenuItem {
+	return MenuItem{Label: label, Submenu: NewMenu(label, nil, items...)}
+}
+
+// AddItems appends the items to the menu, a running ui takes the menus changed with Reload
+func (m *Menu) AddItems(items ...MenuItem) *Menu {
+	m.MenuItems = append(m.MenuItems, items...)
+
+	return m
+}
+
+// Submenu returns the submenu at the path of the item labels e.g. "System", "Power", nil if there is none
+func (m *Menu) Submenu(labels ...string) *Menu {
+	menu := m
+
+	for _, label := range labels {
+		var next *Menu
+
+		for _, item := range menu.MenuItems {
+			if item.Label == label && item.Submenu != nil {
+				next = item.Submenu
+				break
+			}
+		}
+
+		if next == nil {
+			return nil
+		}
+
+		menu = next
+	}
+
+	return menu
+}
+
+// menuState returns a copy of the shown menu and the labels of the open menus to draw it while the navigation
+// changes it
+func (ui *NasUI) menuState() (Menu, []string) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	if len(ui.menuStack) == 0 {
+		if ui.Menu == nil {
+			return Menu{}, nil
+		}
+
+		return *ui.Menu, []string{ui.Menu.Label}
+	}
+
+	labels := make([]string, len(ui.menuStack))
+	for i, menu := range ui.menuStack {
+		labels[i] = menu.Label
+	}
+
+	return *ui.menuStack[len(ui.menuStack)-1], labels
+}
+
+// currentMenu returns the shown menu or the one of the shown menu item page, ui.mu is held
+func (ui *NasUI) currentMenu() *Menu {
+	if len(ui.menuStack) == 0 {
+		return nil
+	}
+
+	return ui.menuStack[len(ui.menuStack)-1]
+}
+
+// menuPage returns the page drawing the current menu, ui.mu is held
+func (ui *NasUI) menuPage() *Page {
+	for i := len(ui.menuStack) - 1; i >= 0; i-- {
+		if ui.menuStack[i].Page != nil {
+			return ui.menuStack[i].Page
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package nasui
+
+import (
+	"testing"
+)
+
+// testMenuUI returns a ui on its first page with the menu of a submenu between two page items
+func testMenuUI() (*NasUI, *Menu) {
+	ui := &NasUI{}
+	ui.AddPages(testPages("One", "Two")...)
+
+	menu := NewMenu("Menu", &Page{Name: "Menu page"},
+		PageItem("Info", &Page{Name: "Info"}),
+		SubmenuItem("System",
+			PageItem("Disks", &Page{Name: "Disks"}),
+			PageItem("Network", &Page{Name: "Network"}),
+		),
+		PageItem("About", &Page{Name: "About"}),
+	)
+	ui.Menu = menu
+
+	return ui, menu
+}
+
+func (ui *NasUI) testMenuLabels() []string {
+	_, labels := ui.menuState()
+
+	return labels
+}
+
+func TestMenuSubmenu(t *testing.T) {
+	ui, menu := testMenuUI()
+
+	ui.doAction(ActionMenu)
+	ui.doAction(ActionNext)
+	ui.doAction(ActionMenu)
+
+	if labels := ui.testMenuLabels(); len(labels) != 2 || labels[1] != "System" {
+		t.Fatalf("open menus %v, want Menu, System", labels)
+	}
+
+	// the submenu is drawn by the page of its parent
+	if page := ui.testActivePage(); page != menu.Page {
+		t.Errorf("shown %q, want the menu page", page.Name)
+	}
+
+	ui.doAction(ActionNext)
+	ui.doAction(ActionMenu)
+
+	if page := ui.testActivePage(); page.Name != "Network" {
+		t.Errorf("shown %q, want the Network item page", page.Name)
+	}
+}
+
+func TestMenuBack(t *testing.T) {
+	ui, menu := testMenuUI()
+	system := menu.Submenu("System")
+
+	ui.doAction(ActionNext)
+	ui.doAction(ActionMenu)
+	ui.doAction(ActionNext)
+	ui.doAction(ActionMenu)
+	ui.doAction(ActionNext)
+	ui.doAction(ActionMenu)
+
+	steps := []struct {
+		page   string
+		labels []string
+	}{
+		// the page of an item goes back to its menu
+		{"Menu page", []string{"Menu", "System"}},
+		{"Menu page", []string{"Menu"}},
+		// the closed menu goes back to the page it was opened from
+		{"Two", nil},
+		// Back goes home from the pages
+		{"One", nil},
+	}
+
+	for i, step := range steps {
+		ui.doAction(ActionBack)
+
+		if page := ui.testActivePage(); page.Name != step.page {
+			t.Fatalf("back %d shows %q, want %q", i+1, page.Name, step.page)
+		}
+
+		ui.mu.Lock()
+		open := len(ui.menuStack)
+		ui.mu.Unlock()
+
+		if open != len(step.labels) {
+			t.Fatalf("back %d leaves %d menus open, want %v", i+1, open, step.labels)
+		}
+
+		if open > 0 {
+			if labels := ui.testMenuLabels(); labels[len(labels)-1] != step.labels[len(step.labels)-1] {
+				t.Errorf("back %d shows the menu %v, want %v", i+1, labels, step.labels)
+			}
+		}
+	}
+
+	if system.ItemIndex != 1 {
+		t.Errorf("the submenu selection is %d, want the Network item kept", system.ItemIndex)
+	}
+}
+
+func TestMenuSelectionKept(t *testing.T) {
+	ui, menu := testMenuUI()
+
+	ui.doAction(ActionMenu)
+	ui.doAction(ActionPrev)
+
+	if menu.ItemIndex != 2 {
+		t.Fatalf("prev from the first item selects %d, want the last one", menu.ItemIndex)
+	}
+
+	// the selection stays while a submenu is open and after the menu is closed and opened again
+	ui.doAction(ActionPrev)
+	ui.doAction(ActionMenu)
+	ui.doAction(ActionBack)
+
+	if menu.ItemIndex != 1 {
+		t.Errorf("back from the submenu selects %d, want System", menu.ItemIndex)
+	}
+
+	ui.doAction(ActionBack)
+	ui.doAction(ActionMenu)
+
+	if menu.ItemIndex != 1 {
+		t.Errorf("the reopened menu selects %d, want System", menu.ItemIndex)
+	}
+
+	// a submenu opens on its first item
+	system := menu.Submenu("System")
+	system.ItemIndex = 1
+
+	ui.doAction(ActionMenu)
+
+	if system.ItemIndex != 0 {
+		t.Errorf("the opened submenu selects %d, want the first item", system.ItemIndex)
+	}
+
+	// home closes the menus and resets the selection
+	ui.doAction(ActionHome)
+
+	if menu.ItemIndex != 0 {
+		t.Errorf("home leaves %d selected, want the first item", menu.ItemIndex)
+	}
+}
@@ -1,11 +1,15 @@
 package nasui
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/llgcode/draw2d/draw2dimg"
 	"image"
 	"log"
 	"nas-kit-ui/pkg/epd"
+	"nas-kit-ui/pkg/led"
+	"sync"
 	"time"
 )
 
@@ -14,11 +18,6 @@ const (
 	DisplayTypeMenu
 )
 
-const (
-	DisplayWidth = 250
-	DisplayHeight = 122
-)
-
 const (
 	OrientationVertical = iota
 	OrientationHorizontal
@@ -27,255 +26,754 @@ const (
 type NasUI struct {
 	Epd *epd.Epaper
 	Menu *Menu
+	// Pages are changed with AddPages, RemovePage, MovePage or Reload while the ui runs
 	Pages []*Page
 	BackgroundProc func(ctx *Context) error
 	IndexPageName string
 	Orientation int
 	pageIndex int
 	displayType int
+	// DefaultUI draws the error pages and the screens of the commands, the dialogs and the editors. A page
+	// failing with no DefaultUI stops Run, the built-in screens are drawn by a DefaultUI of the panel then.
 	DefaultUI *DefaultUI
+	// RefreshPolicy forces full refreshes against ghosting, DefaultRefreshPolicy is used if nil
+	RefreshPolicy *RefreshPolicy
+	// Buttons is the timing of the button events, epd.DefaultButtonConfig is used if nil
+	Buttons *epd.ButtonConfig
+	// Bindings map the button events to actions, DefaultBindings are used if nil
+	Bindings []Binding
+	// Led shows the state of the system with the led patterns, the led is only toggled by ActionToggleLed if nil
+	Led *led.Controller
+	// FinalPage is shown while the panel sleeps after Run stops, the panel is cleared if it displays no image
+	// and it keeps the last page if FinalPage is nil
+	FinalPage *Page
+	// StateStore keeps the shown page, the menu selections, the led and the panel state across the restarts,
+	// Run starts from the IndexPageName if nil
+	StateStore StateStore
+	buttons buttonState
+	ledOn bool
+	fullRefreshRequested bool
+	// mu guards the pages, the navigation state and the running state, they are changed by the render loop
+	// and by the page API from other goroutines
+	mu sync.Mutex
+	activePage *Page
+	// menuStack are the open menus from the Menu to the shown one, it is kept while a page of a menu item
+	// is shown so ActionBack returns to its menu
+	menuStack []*Menu
+	// dialog is the open Confirm, it gets the navigation actions
+	dialog *dialog
+	// task is the running Command
+	task *task
+	// editing is the open Editor, it gets the navigation actions
+	editing *editing
+	redraw bool
+	wake chan struct{}
+	running bool
+	cancel context.CancelFunc
+	ctx context.Context
 	currentPage *Page
+	partialInited bool
+	// fallbackUI draws the built-in screens when DefaultUI is nil
+	fallbackUI *DefaultUI
+	fallbackOnce sync.Once
+	// resumed is set when Run keeps the screen of the previous run, the first page is drawn without clearing it
+	resumed bool
 	Debug bool
 }
 
+// Page is drawn by the render loop, its counters are only used by the loop and the Display callback
 type Page struct {
 	Name string
+	// RefreshInterval is changed with SetRefreshInterval or StopRefreshing while the ui runs
 	RefreshInterval float64
+	// Refresh is the preferred refresh: RefreshAuto, RefreshPartial or RefreshFull
+	Refresh int
+	// FullRedraw is the same as Refresh: RefreshFull
 	FullRedraw bool
+	// Conversion overrides the e-paper conversion to 1-bit for this page
+	Conversion *epd.Conversion
 	Display func(ctx *Context) (*image.RGBA, error)
-	puCnt int
+	displayCnt int
 	drawnAt time.Time
+	mu sync.Mutex
 }
 
 type Menu struct {
 	MenuItems []MenuItem
+	// Label is the menu header, the labels of the open menus are shown as the breadcrumbs
 	Label string
+	// Page draws the menu, the submenus without a Page are drawn by the Page of their parent
 	Page *Page
 	ItemIndex int
 	PerPage int
 }
 
+// MenuItem opens the Submenu or the Editor if set, it runs the Command or shows the Page otherwise
 type MenuItem struct {
 	Label string
 	Page *Page
+	Submenu *Menu
+	Editor Editor
+	// Command is run instead of showing the Page
+	Command *Command
+	// Confirm is asked before the Command is run or the Page is shown
+	Confirm *Confirm
 }
 
+// Context is passed to the pages and the BackgroundProc, it is done when the ui stops
 type Context struct {
+	context.Context
 	NasUI *NasUI
 	DefaultUI *DefaultUI
 }
 
+// PageError is returned by Run when a page fails to render and there is no DefaultUI to show the error
+type PageError struct {
+	Page string
+	Err error
+}
+
 var (
 	ErrIndexPageNotFound = errors.New("index page not found")
 	ErrNoPages = errors.New("no pages added to the ui")
+	ErrPageNotFound = errors.New("page not found")
+	ErrInvalidPageIndex = errors.New("page index out of range")
+)
+
+// fallbackFont is the name the built-in font is registered with for the fallbackUI
+const fallbackFont = "default"
+
+const (
+	// panelRetries is how many times the panel is reinitialized after a failure before Run gives up
+	panelRetries = 3
+	panelRetryDelay = 2 * time.Second
 )
 
+func (e *PageError) Error() string {
+	return fmt.Sprintf("page %q: %v", e.Page, e.Err)
+}
+
+func (e *PageError) Unwrap() error {
+	return e.Err
+}
+
 func (p *Page) ResetCounters()  {
 	p.drawnAt = time.Time{}
-	p.puCnt = 0
+	p.displayCnt = 0
 }
 
 func (p *Page) IsFirstTimeDisplay() bool {
-	return p.puCnt == 0
+	return p.displayCnt == 0
 }
 
 func (p *Page) StopRefreshing()  {
-	p.RefreshInterval = 0
+	p.SetRefreshInterval(0)
 }
 
 func (p *Page) SetRefreshInterval(refreshInterval float64)  {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	p.RefreshInterval = refreshInterval
 }
 
+// refreshDelay returns how long until the page is to be drawn again, false if it is only drawn on a change
+func (p *Page) refreshDelay(now time.Time) (time.Duration, bool) {
+	if p.drawnAt.IsZero() {
+		return 0, true
+	}
+
+	p.mu.Lock()
+	refreshInterval := p.RefreshInterval
+	p.mu.Unlock()
+
+	if refreshInterval <= 0 {
+		return 0, false
+	}
+
+	interval := time.Duration(refreshInterval * float64(time.Second))
+
+	return p.drawnAt.Add(interval).Sub(now), true
+}
+
+// GetCurrentPage returns the page shown on the panel
 func (ui *NasUI) GetCurrentPage() *Page  {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
 	return ui.currentPage
 }
 
-func (ui *NasUI) getPageForButton(btn int) *Page  {
-	switch btn {
-	case epd.BtnOk:
-		if ui.Menu != nil && ui.Menu.Page != nil {
-			if ui.displayType == DisplayTypePage {
-				ui.displayType = DisplayTypeMenu
+// pageForAction returns the page the navigation action leads to, nil if the action does not navigate,
+// ui.mu is held
+func (ui *NasUI) pageForAction(action Action) *Page  {
+	if ui.dialog != nil {
+		return ui.dialogAction(action)
+	}
+
+	if ui.editing != nil {
+		return ui.editorAction(action)
+	}
+
+	switch action {
+	case ActionMenu:
+		if ui.displayType == DisplayTypePage {
+			if ui.Menu == nil || ui.Menu.Page == nil {
+				return nil
+			}
 
-				return ui.Menu.Page
-			} else if ui.displayType == DisplayTypeMenu {
-				menuItem := ui.Menu.MenuItems[ui.Menu.ItemIndex]
+			ui.menuStack = []*Menu{ui.Menu}
+			ui.displayType = DisplayTypeMenu
 
-				if menuItem.Page != nil {
-					ui.displayType = DisplayTypePage
+			return ui.Menu.Page
+		}
 
-					return menuItem.Page
-				}
+		menu := ui.currentMenu()
+		if menu == nil || len(menu.MenuItems) == 0 {
+			return nil
+		}
+
+		menuItem := menu.MenuItems[menu.ItemIndex]
+
+		if menuItem.Submenu != nil {
+			menuItem.Submenu.ItemIndex = 0
+			ui.menuStack = append(ui.menuStack, menuItem.Submenu)
+
+			return ui.menuPage()
+		}
+
+		if menuItem.Editor != nil {
+			return ui.openEditor(menuItem.Editor, menuItem.Label)
+		}
+
+		if menuItem.Confirm != nil {
+			yesPage := menuItem.Confirm.Yes
+			if yesPage == nil {
+				yesPage = menuItem.Page
 			}
+
+			return ui.openDialog(menuItem.Confirm, menuItem.Label, yesPage, menuItem.Command)
 		}
-	case epd.BtnBack:
-		ui.pageIndex = 0
-		ui.Menu.ItemIndex = 0
-		ui.displayType = DisplayTypePage
 
-		return ui.Pages[0]
-	case epd.BtnAdd: // previous
-		if ui.displayType == DisplayTypePage {
-			if ui.pageIndex-1 >= 0 {
-				ui.pageIndex--
-			} else {
-				ui.pageIndex = len(ui.Pages) - 1
+		if menuItem.Command != nil {
+			ui.displayType = DisplayTypePage
+
+			return ui.startCommand(menuItem.Command, menuItem.Label)
+		}
+
+		if menuItem.Page != nil {
+			ui.displayType = DisplayTypePage
+
+			return menuItem.Page
+		}
+	case ActionBack:
+		if ui.displayType == DisplayTypeMenu {
+			ui.menuStack = ui.menuStack[:len(ui.menuStack)-1]
+
+			if len(ui.menuStack) > 0 {
+				return ui.menuPage()
 			}
 
+			// the menu is closed, the page it was opened from is shown again
+			ui.displayType = DisplayTypePage
+
 			return ui.Pages[ui.pageIndex]
-		} else if ui.displayType == DisplayTypeMenu {
-			idx := ui.Menu.ItemIndex
+		}
 
-			if idx - 1 >= 0 {
-				idx--
-			} else {
-				idx = len(ui.Menu.MenuItems) - 1
-			}
+		if len(ui.menuStack) > 0 {
+			// the page of a menu item goes back to its menu
+			ui.displayType = DisplayTypeMenu
 
-			ui.Menu.ItemIndex = idx
+			return ui.menuPage()
+		}
 
-			return ui.Menu.Page
+		return ui.pageForAction(ActionHome)
+	case ActionHome:
+		ui.pageIndex = 0
+		ui.menuStack = nil
+		if ui.Menu != nil {
+			ui.Menu.ItemIndex = 0
 		}
+		ui.displayType = DisplayTypePage
 
-	case epd.BtnSub: // next
-		if ui.displayType == DisplayTypePage {
-			if ui.pageIndex+1 < len(ui.Pages) {
-				ui.pageIndex++
-			} else {
-				ui.pageIndex = 0
+		return ui.Pages[0]
+	case ActionPrev, ActionNext:
+		if ui.displayType == DisplayTypeMenu {
+			menu := ui.currentMenu()
+			if len(menu.MenuItems) == 0 {
+				return nil
 			}
 
-			return ui.Pages[ui.pageIndex]
-		} else if ui.displayType == DisplayTypeMenu {
-			idx := ui.Menu.ItemIndex
-			if idx + 1 < len(ui.Menu.MenuItems) {
-				idx++
+			if action == ActionPrev {
+				menu.ItemIndex = (menu.ItemIndex - 1 + len(menu.MenuItems)) % len(menu.MenuItems)
 			} else {
-				idx = 0
+				menu.ItemIndex = (menu.ItemIndex + 1) % len(menu.MenuItems)
 			}
 
-			ui.Menu.ItemIndex = idx
+			return ui.menuPage()
+		}
 
-			return ui.Menu.Page
+		// the pages are browsed from a menu item page too, the menus are closed then
+		ui.menuStack = nil
+
+		if action == ActionPrev {
+			ui.pageIndex = (ui.pageIndex - 1 + len(ui.Pages)) % len(ui.Pages)
+		} else {
+			ui.pageIndex = (ui.pageIndex + 1) % len(ui.Pages)
 		}
+
+		return ui.Pages[ui.pageIndex]
 	}
 
 	return nil
 }
 
-func (ui *NasUI) Run() error {
-	if len(ui.Pages) == 0 {
-		return ErrNoPages
-	}
+// doAction runs the action, the navigation actions change the active page
+func (ui *NasUI) doAction(action Action) {
+	switch action {
+	case ActionNone:
+		return
+	case ActionRefresh:
+		ui.Refresh()
+
+		return
+	case ActionFullRefresh:
+		ui.fullRefreshRequested = true
+		ui.Refresh()
+
+		return
+	case ActionToggleLed:
+		if ui.Led != nil {
+			ui.Led.SetEnabled(!ui.Led.Enabled())
+
+			return
+		}
 
-	idx := 0
+		ui.ledOn = !ui.ledOn
 
-	if ui.IndexPageName != "" {
-		idx = ui.getIndexPage(ui.IndexPageName)
-		if idx == -1 {
-			return ErrIndexPageNotFound
+		if ui.ledOn {
+			ui.Epd.OnLed()
+		} else {
+			ui.Epd.OffLed()
 		}
+
+		return
 	}
 
-	ui.pageIndex = idx
-	activePage := ui.Pages[idx]
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
 
-	if ui.Debug {
-		img, _ := activePage.Display(ui.createContext())
-		err := draw2dimg.SaveToPngFile("./debug.png", img)
+	page := ui.pageForAction(action)
+	if page != nil {
+		// the menu pages stay the same while the selection changes, so the page is redrawn
+		ui.activePage = page
+		ui.redraw = true
+	}
+}
 
+// Run shows the pages until the ctx is done or Stop is called, the button readers, the led and the render loop
+// are stopped then and the panel is put to sleep showing the FinalPage. The BackgroundProc is to return
+// once the context passed to it is done. Run returns nil when stopped, the process exit is up to the caller.
+// Run starts from the page saved in the StateStore if it is still there.
+func (ui *NasUI) Run(ctx context.Context) error {
+	state := ui.loadState()
+
+	page, err := ui.startPage(state)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ui.ctx = ctx
+
+	if ui.Debug {
+		img, err := page.Display(ui.createContext())
 		if err != nil {
-			return err
+			return &PageError{Page: page.Name, Err: err}
 		}
 
-		return nil
+		if img == nil {
+			return nil
+		}
+
+		return draw2dimg.SaveToPngFile("./debug.png", img)
 	}
 
-	err := ui.Epd.InitBoard()
+	err = ui.Epd.InitBoard()
 	if err != nil {
 		return err
 	}
 
-	err = ui.Epd.InitFull()
+	if ui.resumesPanel(state) {
+		// the panel has no ghosting to clear, the first page is drawn with a full refresh anyway
+		ui.partialInited = false
+		ui.Epd.SetLastFullRefresh(state.LastFullRefresh)
+		ui.resumed = true
+		err = ui.Epd.InitFull()
+	} else {
+		err = ui.initPanel()
+	}
+
 	if err != nil {
-		return err
+		return ui.shutdown(err)
 	}
 
-	ui.Epd.Reset()
-	ui.Epd.Clear(epd.BgColorWhite)
+	ui.restoreLed(state)
 
-	ui.displayType = DisplayTypePage
+	buttonConfig := epd.DefaultButtonConfig
+	if ui.Buttons != nil {
+		buttonConfig = *ui.Buttons
+	}
+
+	buttons, err := ui.Epd.Buttons(ctx, buttonConfig)
+	if err != nil {
+		return ui.shutdown(err)
+	}
+
+	wake := make(chan struct{}, 1)
+
+	ui.mu.Lock()
+	ui.wake = wake
+	ui.cancel = cancel
+	ui.running = true
+	ui.mu.Unlock()
+
+	defer func() {
+		ui.mu.Lock()
+		ui.running = false
+		ui.cancel = nil
+		ui.mu.Unlock()
+	}()
+
+	errorChan := make(chan error, 1)
+
+	// report passes the first error to Run, the later ones are dropped as Run is stopping already
+	report := func(err error) {
+		select {
+		case errorChan <- err:
+		default:
+		}
+	}
+
+	// the panel and the led are released only after the render loop, the led and the BackgroundProc are stopped
+	var wg sync.WaitGroup
 
-	buttonsChan := make(chan int)
-	errorChan := make(chan error)
+	if ui.Led != nil {
+		ui.setLedState(led.StateHealthy, true)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := ui.Led.Run(ctx)
+
+			if err != nil && ctx.Err() == nil {
+				report(err)
+			}
+		}()
+	}
 
 	if ui.BackgroundProc != nil {
+		wg.Add(1)
 		go func() {
+			defer wg.Done()
+
 			err := ui.BackgroundProc(ui.createContext())
 
 			if err != nil {
-				errorChan <- err
+				report(err)
 			}
 		}()
 	}
 
+	wg.Add(1)
 	go func() {
-		ui.Epd.ReadButtons(buttonsChan)
-	}()
+		defer wg.Done()
+
+		// the loop sleeps until the refresh of the page is due, a button is pressed or the pages are changed
+		timer := time.NewTimer(0)
+		stopTimer(timer)
+
+		var prevPage *Page
 
-	go func() {
 		for {
-			select {
-			case btn := <- buttonsChan:
-				oldPage := activePage
-				oldPage.ResetCounters()
+			ui.mu.Lock()
+			ui.expireDialog(time.Now())
+			activePage := ui.activePage
+			redraw := ui.redraw
+			ui.redraw = false
+			ui.mu.Unlock()
 
-				activePage = ui.getPageForButton(btn)
-			default:
+			if activePage == nil {
+				report(errors.New("no page do display"))
+				return
 			}
 
-			if activePage == nil {
-				errorChan <- errors.New("no page do display")
+			if prevPage != nil && prevPage != activePage {
+				// the page left is drawn as the first time when it is shown again
+				prevPage.ResetCounters()
 			}
 
-			if !activePage.drawnAt.IsZero() &&
-				(activePage.RefreshInterval == 0 || time.Now().Sub(activePage.drawnAt).Seconds() < activePage.RefreshInterval) {
-				continue
+			prevPage = activePage
+
+			if redraw {
+				activePage.drawnAt = time.Time{}
 			}
 
-			err := ui.displayPage(activePage)
+			if delay, ok := activePage.refreshDelay(time.Now()); ok && delay <= 0 {
+				err := ui.showPage(activePage)
 
-			if err != nil {
-				errorChan <- err
+				if err != nil {
+					if ctx.Err() == nil {
+						report(err)
+					}
+
+					return
+				}
+
+				ui.mu.Lock()
+				changed := ui.currentPage != activePage
+				ui.currentPage = activePage
+				ui.mu.Unlock()
+
+				if changed {
+					ui.saveState()
+				}
+			}
+
+			var refreshTimer <-chan time.Time
+
+			if delay, ok := activePage.refreshDelay(time.Now()); ok {
+				timer.Reset(delay)
+				refreshTimer = timer.C
 			}
 
-			ui.currentPage = activePage
+			select {
+			case event, ok := <- buttons:
+				if !ok {
+					return
+				}
+
+				ui.doAction(ui.actionForEvent(event))
+			case <-wake:
+			case <-refreshTimer:
+			case <-ctx.Done():
+				return
+			}
+
+			stopTimer(timer)
 		}
 	}()
 
-	err = <- errorChan
+	select {
+	case err = <- errorChan:
+	case <-ctx.Done():
+	}
+
+	cancel()
+	wg.Wait()
+
+	err = ui.shutdown(err)
+
+	// the state is saved after the FinalPage, it is the last full refresh
+	ui.saveState()
+
+	return err
+}
+
+// startPage shows the index page or the page of the state if it is still there, the pages may be changed
+// by other goroutines meanwhile
+func (ui *NasUI) startPage(state State) (*Page, error) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	if len(ui.Pages) == 0 {
+		return nil, ErrNoPages
+	}
+
+	idx := 0
+
+	if ui.IndexPageName != "" {
+		idx = ui.getIndexPage(ui.IndexPageName)
+		if idx == -1 {
+			return nil, ErrIndexPageNotFound
+		}
+	}
+
+	if saved := ui.getIndexPage(state.Page); state.Page != "" && saved != -1 {
+		idx = saved
+	}
+
+	ui.restoreMenu(state)
+
+	ui.pageIndex = idx
+	ui.activePage = ui.Pages[idx]
+	ui.displayType = DisplayTypePage
+
+	return ui.activePage, nil
+}
+
+// Refresh redraws the shown page as soon as possible e.g. when its data changed, it does not wait for the page
+// RefreshInterval
+func (ui *NasUI) Refresh() {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.redraw = true
+	ui.notify()
+}
+
+// notify wakes the render loop up after a change of the state, ui.mu is held
+func (ui *NasUI) notify() {
+	if !ui.running {
+		return
+	}
+
+	select {
+	case ui.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Stop stops the running ui as if the context of Run was done
+func (ui *NasUI) Stop() {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	if ui.cancel != nil {
+		ui.cancel()
+	}
+}
+
+// shutdown shows the FinalPage, puts the panel to sleep and releases the board, err is the reason
+// of the shutdown and it is returned unless the shutdown fails with no reason
+func (ui *NasUI) shutdown(err error) error {
+	var serr error
+
+	if ui.FinalPage != nil && err == nil {
+		serr = ui.showFinalPage()
+	}
+
+	if serr == nil {
+		serr = ui.Epd.Sleep()
+	}
+
+	if cerr := ui.Epd.Close(); serr == nil {
+		serr = cerr
+	}
 
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return serr
 }
 
-func (ui *NasUI) AddPages(pages ...*Page)  {
-	for _, page := range pages {
-		ui.Pages = append(ui.Pages, page)
+// showFinalPage displays the FinalPage with a full refresh, it stays on the panel while it sleeps
+func (ui *NasUI) showFinalPage() error {
+	err := ui.Epd.InitFull()
+	if err != nil {
+		return err
 	}
+
+	ui.partialInited = false
+
+	img, err := ui.FinalPage.Display(ui.createContext())
+	if err != nil {
+		return &PageError{Page: ui.FinalPage.Name, Err: err}
+	}
+
+	if img == nil {
+		return ui.Epd.Clear(epd.BgColorWhite)
+	}
+
+	return ui.Epd.Display(*img)
+}
+
+// builtinUI returns the DefaultUI drawing the screens of the commands, the dialogs and the editors, one
+// for the panel is created if DefaultUI is nil
+func (ui *NasUI) builtinUI() *DefaultUI {
+	if ui.DefaultUI != nil {
+		return ui.DefaultUI
+	}
+
+	ui.fallbackOnce.Do(func() {
+		ui.fallbackUI = NewDefaultUI(ui.Epd.Panel(), ui.Orientation, fallbackFont)
+	})
+
+	return ui.fallbackUI
 }
 
 func (ui *NasUI) createContext() *Context  {
+	ctx := ui.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
 	return &Context{
+		Context: ctx,
 		NasUI: ui,
 		DefaultUI:   ui.DefaultUI,
 	}
 }
 
+// showPage displays the page, a failing panel is reinitialized and the page shown again
+// up to panelRetries times unless the ui is stopped meanwhile
+func (ui *NasUI) showPage(page *Page) error {
+	err := ui.displayPage(page)
+
+	for retry := 1; err != nil && retry <= panelRetries; retry++ {
+		var pageErr *PageError
+		if errors.As(err, &pageErr) {
+			return err
+		}
+
+		log.Printf("e-paper failed: %v, reinitializing (%d/%d)", err, retry, panelRetries)
+		ui.setLedState(led.StateDriverError, true)
+
+		// a stop does not wait for the retries
+		select {
+		case <-ui.ctx.Done():
+			return ui.ctx.Err()
+		case <-time.After(panelRetryDelay):
+		}
+
+		err = ui.initPanel()
+		if err == nil {
+			err = ui.displayPage(page)
+		}
+	}
+
+	ui.setLedState(led.StateDriverError, err != nil)
+
+	return err
+}
+
+func (ui *NasUI) setLedState(state string, on bool) {
+	if ui.Led != nil {
+		ui.Led.SetState(state, on)
+	}
+}
+
+// initPanel does the full init of the panel and clears it
+func (ui *NasUI) initPanel() error {
+	ui.partialInited = false
+
+	err := ui.Epd.InitFull()
+	if err != nil {
+		return err
+	}
+
+	err = ui.Epd.Reset()
+	if err != nil {
+		return err
+	}
+
+	return ui.Epd.Clear(epd.BgColorWhite)
+}
+
 func (ui *NasUI) displayPage(page *Page) error {
 	defer func() {
 		page.drawnAt = time.Now()
@@ -284,7 +782,13 @@ func (ui *NasUI) displayPage(page *Page) error {
 	img, err := page.Display(ui.createContext())
 
 	if err != nil {
-		return err
+		if ui.DefaultUI == nil {
+			return &PageError{Page: page.Name, Err: err}
+		}
+
+		// the error is shown instead of the page until the next refresh of the page
+		log.Printf("page %q: %v", page.Name, err)
+		img = ui.DefaultUI.ErrorPage(page.Name, err)
 	}
 
 	if img == nil {
@@ -296,7 +800,12 @@ func (ui *NasUI) displayPage(page *Page) error {
 		return err
 	}
 
-	err = ui.Epd.Display(*img)
+	if page.Conversion != nil {
+		err = ui.Epd.DisplayConverted(*img, *page.Conversion)
+	} else {
+		err = ui.Epd.Display(*img)
+	}
+
 	if err != nil {
 		return err
 	}
@@ -305,38 +814,25 @@ func (ui *NasUI) displayPage(page *Page) error {
 }
 
 func (ui *NasUI) getIndexPage(name string) int  {
-	for idx, page := range ui.Pages {
-		if page.Name == name {
-			return idx
-		}
-	}
-
-	return -1
+	return pageIndex(ui.Pages, name)
 }
 
-func (ui *NasUI) initPage(page *Page) error  {
-	if page.puCnt > 1 {
-		return nil
-	}
-
-	if page.puCnt == 0 {
-		err := ui.Epd.InitFull()
-		if err != nil {
-			log.Println(err)
-			return err
+// stopTimer stops the timer and drains its channel, so it can be reset
+func stopTimer(timer *time.Timer) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
 		}
-		ui.Epd.Reset()
-		ui.Epd.Clear(epd.BgColorWhite)
+	}
+}
 
-		page.puCnt++
-	} else if page.puCnt == 1 && !page.FullRedraw {
-		err := ui.Epd.InitPartial()
-		if err != nil {
-			log.Println(err)
-			return err
+func pageIndex(pages []*Page, name string) int {
+	for idx, page := range pages {
+		if page.Name == name {
+			return idx
 		}
-		page.puCnt++
 	}
 
-	return nil
+	return -1
 }
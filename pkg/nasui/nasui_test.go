@@ -0,0 +1,237 @@
+package nasui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"nas-kit-ui/pkg/epd"
+	"nas-kit-ui/pkg/led"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestUI creates a ui of the text pages on the simulated panel, the pages refresh often to keep the loop busy
+func newTestUI(t *testing.T, names ...string) (*NasUI, *epd.Simulator) {
+	t.Helper()
+
+	panel, err := epd.LookupPanel("2in13v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sim := epd.NewSimulator(panel)
+	paper := epd.NewSimulated(sim)
+
+	ui := &NasUI{
+		Epd:       paper,
+		DefaultUI: NewDefaultUI(panel, OrientationVertical, "test"),
+		Buttons: &epd.ButtonConfig{
+			Debounce:    time.Millisecond,
+			LongPress:   50 * time.Millisecond,
+			RepeatEvery: 20 * time.Millisecond,
+		},
+		Led: led.NewController(led.OutputFunc(paper.SetLedDuty), led.DefaultRules),
+		BackgroundProc: func(ctx *Context) error {
+			// the fan is driven until the ui stops as the fan controller does
+			for duty := 0.0; ctx.Err() == nil; duty = 1 - duty {
+				if err := ctx.NasUI.Epd.SetFanDuty(duty); err != nil {
+					return err
+				}
+
+				time.Sleep(time.Millisecond)
+			}
+
+			return nil
+		},
+	}
+
+	ui.AddPages(testPages(names...)...)
+	ui.Menu = NewMenu("Menu", &Page{
+		Name:            "Menu page",
+		RefreshInterval: 0.05,
+		Display: func(ctx *Context) (*image.RGBA, error) {
+			return ctx.DefaultUI.MenuPage(ctx)
+		},
+	},
+		PageItem("Info", testPages("Info")[0]),
+		SubmenuItem("Settings",
+			EditorItem("Number", &NumberEditor{Min: 0, Max: 10, Step: 1, Get: func() float64 { return 5 }, Set: func(float64) error { return nil }}),
+			EditorItem("Toggle", &ToggleEditor{Get: func() bool { return true }, Set: func(bool) error { return nil }}),
+		),
+		CommandItem("Command", &Command{
+			Run: func(ctx *Context) CommandResult {
+				return CommandResult{Text: []string{"done"}}
+			},
+		}),
+	)
+
+	return ui, sim
+}
+
+func testPages(names ...string) []*Page {
+	var pages []*Page
+
+	for _, name := range names {
+		name := name
+
+		pages = append(pages, &Page{
+			Name:            name,
+			RefreshInterval: 0.02,
+			Display: func(ctx *Context) (*image.RGBA, error) {
+				return ctx.NasUI.builtinUI().MenuActionTextPage(name, []string{time.Now().Format(time.StampMicro)}), nil
+			},
+		})
+	}
+
+	return pages
+}
+
+// runUI runs the ui until the returned stop is called, stop returns the error of Run
+func runUI(t *testing.T, ui *NasUI) func() error {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() {
+		done <- ui.Run(ctx)
+	}()
+
+	return func() error {
+		cancel()
+
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(10 * time.Second):
+			t.Fatal("Run did not stop")
+			return nil
+		}
+	}
+}
+
+func TestRunChangesPagesConcurrently(t *testing.T) {
+	ui, sim := newTestUI(t, "One", "Two", "Three")
+	stop := runUI(t, ui)
+
+	var wg sync.WaitGroup
+
+	deadline := time.Now().Add(time.Second)
+
+	// the buttons navigate the pages, the menu, the editors and the commands
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		buttons := []int{epd.BtnSub, epd.BtnOk, epd.BtnSub, epd.BtnOk, epd.BtnAdd, epd.BtnOk, epd.BtnBack, epd.BtnSub}
+
+		for i := 0; time.Now().Before(deadline); i++ {
+			sim.Press(buttons[i%len(buttons)])
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for i := 0; time.Now().Before(deadline); i++ {
+			name := fmt.Sprintf("Added %d", i)
+
+			ui.AddPages(testPages(name)...)
+			_ = ui.MovePage(name, 0)
+			ui.Refresh()
+			_ = ui.RemovePage(name)
+			ui.Refresh()
+
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for i := 0; time.Now().Before(deadline); i++ {
+			names := []string{"One", "Two", "Three"}
+			if i%2 == 1 {
+				names = []string{"Three", "Four"}
+			}
+
+			if err := ui.Reload(testPages(names...), ui.Menu, names[0]); err != nil {
+				t.Error(err)
+				return
+			}
+
+			_ = ui.PageNames()
+			_ = ui.GetCurrentPage()
+
+			time.Sleep(3 * time.Millisecond)
+		}
+	}()
+
+	wg.Wait()
+
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	displays := 0
+
+	for _, call := range sim.Calls() {
+		if call.Op == epd.SimOpDisplay && !call.Ignored {
+			displays++
+		}
+	}
+
+	if displays == 0 {
+		t.Error("no page displayed")
+	}
+
+	if last := sim.Calls()[len(sim.Calls())-1]; last.Op != epd.SimOpSleep {
+		t.Errorf("the panel is not put to sleep after Run, last call %+v", last)
+	}
+
+	if duty := sim.Hardware().Fan.Duty(); duty != 0 {
+		t.Errorf("fan duty %.2f after Run, want 0", duty)
+	}
+}
+
+func TestRunStopsDuringPanelRetries(t *testing.T) {
+	ui, sim := newTestUI(t, "One")
+	stop := runUI(t, ui)
+
+	time.Sleep(100 * time.Millisecond)
+	sim.Fail(fmt.Errorf("broken"))
+	ui.Refresh()
+
+	// the retry waits for panelRetryDelay
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+
+	if err := stop(); err == nil {
+		t.Error("Run of a broken panel returned no error")
+	}
+
+	if d := time.Since(start); d >= panelRetryDelay {
+		t.Errorf("Run stopped after %s, want sooner than the retry delay %s", d, panelRetryDelay)
+	}
+}
+
+func TestRunDebugReportsPageError(t *testing.T) {
+	ui, _ := newTestUI(t, "One")
+	ui.Debug = true
+	ui.Pages[0].Display = func(ctx *Context) (*image.RGBA, error) {
+		return nil, fmt.Errorf("no data")
+	}
+
+	err := ui.Run(context.Background())
+
+	var pageErr *PageError
+	if !errors.As(err, &pageErr) || pageErr.Page != "One" {
+		t.Errorf("Run of a failing page in debug mode returned %v, want a PageError", err)
+	}
+}
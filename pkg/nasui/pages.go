@@ -0,0 +1,198 @@
+package nasui
+
+// AddPages appends the pages, they can be added while the ui runs
+func (ui *NasUI) AddPages(pages ...*Page)  {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.setPages(append(append([]*Page{}, ui.Pages...), pages...))
+}
+
+// RemovePage removes the page of the name, the index page is shown if the page was shown.
+// The last page is not removed.
+func (ui *NasUI) RemovePage(name string) error {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	idx := ui.getIndexPage(name)
+	if idx == -1 {
+		return ErrPageNotFound
+	}
+
+	if len(ui.Pages) == 1 {
+		return ErrNoPages
+	}
+
+	if ui.IndexPageName == name {
+		ui.IndexPageName = ""
+	}
+
+	pages := make([]*Page, 0, len(ui.Pages) - 1)
+	pages = append(pages, ui.Pages[:idx]...)
+	pages = append(pages, ui.Pages[idx+1:]...)
+
+	ui.setPages(pages)
+
+	return nil
+}
+
+// MovePage moves the page of the name to the index, the other pages keep their order
+func (ui *NasUI) MovePage(name string, index int) error {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	idx := ui.getIndexPage(name)
+	if idx == -1 {
+		return ErrPageNotFound
+	}
+
+	if index < 0 || index >= len(ui.Pages) {
+		return ErrInvalidPageIndex
+	}
+
+	page := ui.Pages[idx]
+
+	pages := make([]*Page, 0, len(ui.Pages))
+	pages = append(pages, ui.Pages[:idx]...)
+	pages = append(pages, ui.Pages[idx+1:]...)
+	pages = append(pages[:index], append([]*Page{page}, pages[index:]...)...)
+
+	ui.setPages(pages)
+
+	return nil
+}
+
+// PageNames returns the names of the pages in their order
+func (ui *NasUI) PageNames() []string {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	names := make([]string, len(ui.Pages))
+	for i, page := range ui.Pages {
+		names[i] = page.Name
+	}
+
+	return names
+}
+
+// Page returns the page of the name, nil if there is none
+func (ui *NasUI) Page(name string) *Page {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	idx := ui.getIndexPage(name)
+	if idx == -1 {
+		return nil
+	}
+
+	return ui.Pages[idx]
+}
+
+// SetIndexPage makes the page of the name the index page, it is shown when Run starts again, after a reload
+// or when the shown page is removed
+func (ui *NasUI) SetIndexPage(name string) error {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	if ui.getIndexPage(name) == -1 {
+		return ErrPageNotFound
+	}
+
+	ui.IndexPageName = name
+
+	return nil
+}
+
+// Reload replaces the pages, the menu and the index page at once, a running ui shows them after the page
+// it draws now. The shown page stays if a page of the same name is reloaded, the index page is shown
+// otherwise. Nothing is changed when the new pages are invalid.
+func (ui *NasUI) Reload(pages []*Page, menu *Menu, indexPageName string) error {
+	if len(pages) == 0 {
+		return ErrNoPages
+	}
+
+	if indexPageName != "" && pageIndex(pages, indexPageName) == -1 {
+		return ErrIndexPageNotFound
+	}
+
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	idx := -1
+	if ui.displayType == DisplayTypePage && ui.activePage != nil {
+		idx = pageIndex(pages, ui.activePage.Name)
+	}
+
+	ui.Pages, ui.Menu, ui.IndexPageName = pages, menu, indexPageName
+	ui.showPageAt(idx)
+
+	return nil
+}
+
+// setPages replaces the pages keeping the shown page, the index page is shown if the shown page is gone,
+// ui.mu is held
+func (ui *NasUI) setPages(pages []*Page) {
+	var current *Page
+	if ui.pageIndex < len(ui.Pages) {
+		current = ui.Pages[ui.pageIndex]
+	}
+
+	ui.Pages = pages
+
+	idx := indexOf(pages, current)
+	if idx != -1 {
+		ui.pageIndex = idx
+		return
+	}
+
+	if ui.activePage != nil && ui.activePage != current {
+		// a menu page or a dialog is shown, only the page the navigation goes back to changes
+		ui.pageIndex = ui.indexPageOrFirst()
+
+		if ui.dialog != nil && ui.dialog.backPage == current {
+			ui.dialog.backPage = ui.Pages[ui.pageIndex]
+		}
+
+		return
+	}
+
+	ui.showPageAt(-1)
+}
+
+// showPageAt makes the page at the idx the active one, the index page if idx is -1, ui.mu is held
+func (ui *NasUI) showPageAt(idx int) {
+	if idx == -1 {
+		idx = ui.indexPageOrFirst()
+	}
+
+	if idx >= len(ui.Pages) {
+		return
+	}
+
+	ui.pageIndex = idx
+	ui.displayType = DisplayTypePage
+	ui.menuStack = nil
+	ui.dialog = nil
+	ui.editing = nil
+	ui.activePage = ui.Pages[idx]
+	ui.notify()
+}
+
+func (ui *NasUI) indexPageOrFirst() int {
+	idx := ui.getIndexPage(ui.IndexPageName)
+	if idx == -1 {
+		return 0
+	}
+
+	return idx
+}
+
+func indexOf(pages []*Page, page *Page) int {
+	for idx, p := range pages {
+		if p == page {
+			return idx
+		}
+	}
+
+	return -1
+}
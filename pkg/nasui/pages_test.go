@@ -0,0 +1,101 @@
+package nasui
+
+import (
+	"testing"
+)
+
+func TestReloadInvalidKeepsPrevious(t *testing.T) {
+	ui := &NasUI{IndexPageName: "Two"}
+	ui.AddPages(testPages("One", "Two")...)
+	menu := NewMenu("Menu", nil)
+	ui.Menu = menu
+
+	ui.doAction(ActionNext)
+	shown := ui.testActivePage()
+
+	tests := []struct {
+		name  string
+		pages []*Page
+		index string
+		want  error
+	}{
+		{"no pages", nil, "", ErrNoPages},
+		{"unknown index page", testPages("Three"), "Four", ErrIndexPageNotFound},
+	}
+
+	for _, test := range tests {
+		if err := ui.Reload(test.pages, NewMenu("Other", nil), test.index); err != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.want)
+		}
+
+		if names := ui.PageNames(); len(names) != 2 || names[0] != "One" || names[1] != "Two" {
+			t.Errorf("%s: pages %v, want the previous ones", test.name, names)
+		}
+
+		if ui.Menu != menu || ui.IndexPageName != "Two" {
+			t.Errorf("%s: the menu or the index page was replaced", test.name)
+		}
+
+		if page := ui.testActivePage(); page != shown {
+			t.Errorf("%s: shown %q, want %q", test.name, page.Name, shown.Name)
+		}
+	}
+}
+
+func TestReloadKeepsShownPage(t *testing.T) {
+	ui := &NasUI{}
+	ui.AddPages(testPages("One", "Two")...)
+
+	ui.doAction(ActionNext)
+
+	pages := testPages("Zero", "One", "Two", "Three")
+
+	if err := ui.Reload(pages, nil, "Zero"); err != nil {
+		t.Fatal(err)
+	}
+
+	if page := ui.testActivePage(); page != pages[2] {
+		t.Fatalf("shown %q, want the reloaded Two", page.Name)
+	}
+
+	// the navigation goes on from the reloaded page
+	ui.doAction(ActionNext)
+
+	if page := ui.testActivePage(); page != pages[3] {
+		t.Errorf("next shows %q, want Three", page.Name)
+	}
+
+	pages = testPages("Four", "Five")
+
+	if err := ui.Reload(pages, nil, "Five"); err != nil {
+		t.Fatal(err)
+	}
+
+	if page := ui.testActivePage(); page != pages[1] {
+		t.Errorf("shown %q, want the index page as the shown one is gone", page.Name)
+	}
+}
+
+func TestReloadClosesMenu(t *testing.T) {
+	ui, _ := newTestUI(t, "One", "Two")
+
+	ui.doAction(ActionNext)
+	ui.doAction(ActionMenu)
+
+	pages := testPages("One", "Two")
+
+	if err := ui.Reload(pages, ui.Menu, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if page := ui.testActivePage(); page != pages[0] {
+		t.Errorf("shown %q, want the first page", page.Name)
+	}
+
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	if ui.displayType != DisplayTypePage || ui.menuStack != nil {
+		t.Error("the menu is still open after the reload")
+	}
+}
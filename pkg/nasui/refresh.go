@@ -0,0 +1,89 @@
+package nasui
+
+import (
+	"time"
+)
+
+const (
+	// RefreshAuto does a full refresh when the page is shown and partial ones while it stays
+	RefreshAuto = iota
+	// RefreshPartial prefers partial refresh even when the page is shown
+	RefreshPartial
+	// RefreshFull always does a full refresh
+	RefreshFull
+)
+
+// RefreshPolicy forces a full refresh to clear the ghosting accumulated by partial refreshes
+type RefreshPolicy struct {
+	// MaxPartialUpdates is the number of partial refreshes after which a full one is forced, 0 means no limit
+	MaxPartialUpdates int
+	// MaxPartialAge is the time since the last full refresh after which a full one is forced, 0 means no limit
+	MaxPartialAge time.Duration
+}
+
+// DefaultRefreshPolicy is used when NasUI has no RefreshPolicy set
+var DefaultRefreshPolicy = RefreshPolicy{
+	MaxPartialUpdates: 200,
+	MaxPartialAge:     10 * time.Minute,
+}
+
+// fullRefreshDue tells whether the ghosting limits are reached
+func (rp RefreshPolicy) fullRefreshDue(partialUpdates int, lastFull time.Time) bool {
+	if rp.MaxPartialUpdates > 0 && partialUpdates >= rp.MaxPartialUpdates {
+		return true
+	}
+
+	return rp.MaxPartialAge > 0 && !lastFull.IsZero() && time.Since(lastFull) >= rp.MaxPartialAge
+}
+
+func (ui *NasUI) refreshPolicy() RefreshPolicy {
+	if ui.RefreshPolicy != nil {
+		return *ui.RefreshPolicy
+	}
+
+	return DefaultRefreshPolicy
+}
+
+// initPage prepares the panel for the page refresh, it decides between full and partial refresh
+// by the page preference and the refresh policy
+func (ui *NasUI) initPage(page *Page) error {
+	refresh := page.Refresh
+	if page.FullRedraw {
+		refresh = RefreshFull
+	}
+
+	firstDisplay := page.displayCnt == 0
+	page.displayCnt++
+
+	resumed := ui.resumed
+	ui.resumed = false
+
+	forced := ui.fullRefreshRequested || ui.refreshPolicy().fullRefreshDue(ui.Epd.PartialRefreshes(), ui.Epd.LastFullRefresh())
+	ui.fullRefreshRequested = false
+	full := forced || refresh == RefreshFull || (refresh == RefreshAuto && firstDisplay)
+
+	if !full {
+		if ui.partialInited {
+			return nil
+		}
+
+		err := ui.Epd.InitPartial()
+		if err != nil {
+			return err
+		}
+
+		ui.partialInited = true
+
+		return nil
+	}
+
+	// a page shown the first time or a forced refresh starts from the clean screen, except the first page
+	// after a resume which is drawn over the screen kept from the previous run
+	if (firstDisplay && !resumed) || forced {
+		return ui.initPanel()
+	}
+
+	ui.partialInited = false
+
+	return ui.Epd.InitFull()
+}
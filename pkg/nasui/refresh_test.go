@@ -0,0 +1,78 @@
+package nasui
+
+import (
+	"nas-kit-ui/pkg/epd"
+	"testing"
+	"time"
+)
+
+func TestFullRefreshDue(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		policy   RefreshPolicy
+		partials int
+		lastFull time.Time
+		want     bool
+	}{
+		{"below the count", RefreshPolicy{MaxPartialUpdates: 5}, 4, now, false},
+		{"at the count", RefreshPolicy{MaxPartialUpdates: 5}, 5, now, true},
+		{"over the count", RefreshPolicy{MaxPartialUpdates: 5}, 6, now, true},
+		{"younger than the age", RefreshPolicy{MaxPartialAge: time.Minute}, 1, now.Add(-30 * time.Second), false},
+		{"older than the age", RefreshPolicy{MaxPartialAge: time.Minute}, 1, now.Add(-2 * time.Minute), true},
+		{"no full refresh yet", RefreshPolicy{MaxPartialAge: time.Minute}, 1, time.Time{}, false},
+		{"either limit", RefreshPolicy{MaxPartialUpdates: 5, MaxPartialAge: time.Minute}, 1, now.Add(-2 * time.Minute), true},
+		{"0 count disables", RefreshPolicy{MaxPartialAge: time.Hour}, 1000, now, false},
+		{"0 age disables", RefreshPolicy{MaxPartialUpdates: 1000}, 1, now.Add(-24 * time.Hour), false},
+		{"both 0", RefreshPolicy{}, 1000, now.Add(-24 * time.Hour), false},
+	}
+
+	for _, test := range tests {
+		if got := test.policy.fullRefreshDue(test.partials, test.lastFull); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRefreshPolicyForcesFullRefresh(t *testing.T) {
+	const maxPartials = 3
+
+	ui, sim := newTestUI(t, "One")
+	ui.RefreshPolicy = &RefreshPolicy{MaxPartialUpdates: maxPartials}
+
+	stop := runUI(t, ui)
+
+	waitFor(t, "the refreshes", func() bool {
+		return len(sim.Calls()) > 40
+	})
+
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	partials, fulls := 0, 0
+
+	for _, call := range sim.Calls() {
+		if call.Op != epd.SimOpDisplay || call.Ignored {
+			continue
+		}
+
+		if !call.Partial {
+			partials = 0
+			fulls++
+
+			continue
+		}
+
+		partials++
+
+		if partials > maxPartials {
+			t.Fatalf("%d partial refreshes in a row, want at most %d", partials, maxPartials)
+		}
+	}
+
+	if fulls < 2 {
+		t.Errorf("%d full refreshes, want the forced ones too", fulls)
+	}
+}
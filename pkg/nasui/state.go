@@ -0,0 +1,113 @@
+package nasui
+
+import (
+	"log"
+	"time"
+)
+
+// State is the state of the ui kept across the restarts, so the display resumes where it was
+type State struct {
+	// Page is the name of the shown page, the page a menu was opened from while it is shown
+	Page string `json:"page,omitempty"`
+	// MenuItem is the selected item of the Menu, the submenus select their first item when opened anyway
+	MenuItem int `json:"menu_item"`
+	// LedEnabled is whether the led is toggled on by ActionToggleLed
+	LedEnabled *bool `json:"led_enabled,omitempty"`
+	// LastFullRefresh is the time of the last full refresh of the panel, the panel is not cleared on start
+	// when it is more recent than the MaxPartialAge of the RefreshPolicy
+	LastFullRefresh time.Time `json:"last_full_refresh"`
+}
+
+// StateStore keeps the State, Run restores it on start and saves it when the shown page changes and when Run
+// stops. Load returns the zero State when nothing is saved yet.
+type StateStore interface {
+	Load() (State, error)
+	Save(state State) error
+}
+
+// loadState returns the saved state, a state which fails to load is logged and the ui starts from the index page
+func (ui *NasUI) loadState() State {
+	if ui.StateStore == nil {
+		return State{}
+	}
+
+	state, err := ui.StateStore.Load()
+	if err != nil {
+		log.Printf("state not restored: %v", err)
+		return State{}
+	}
+
+	return state
+}
+
+// saveState saves the state of the ui, the failures are logged as the ui keeps running without it
+func (ui *NasUI) saveState() {
+	if ui.StateStore == nil {
+		return
+	}
+
+	err := ui.StateStore.Save(ui.state())
+	if err != nil {
+		log.Printf("state not saved: %v", err)
+	}
+}
+
+// state returns the current state, it is called by the render loop or after it stopped
+func (ui *NasUI) state() State {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	state := State{
+		LastFullRefresh: ui.Epd.LastFullRefresh(),
+	}
+
+	if ui.pageIndex < len(ui.Pages) {
+		state.Page = ui.Pages[ui.pageIndex].Name
+	}
+
+	if ui.Menu != nil {
+		state.MenuItem = ui.Menu.ItemIndex
+	}
+
+	ledEnabled := ui.ledOn
+	if ui.Led != nil {
+		ledEnabled = ui.Led.Enabled()
+	}
+
+	state.LedEnabled = &ledEnabled
+
+	return state
+}
+
+// restoreMenu selects the saved item of the Menu if it still has it, ui.mu is held
+func (ui *NasUI) restoreMenu(state State) {
+	if ui.Menu != nil && state.MenuItem >= 0 && state.MenuItem < len(ui.Menu.MenuItems) {
+		ui.Menu.ItemIndex = state.MenuItem
+	}
+}
+
+// restoreLed toggles the led as it was, the board is initialized
+func (ui *NasUI) restoreLed(state State) {
+	if state.LedEnabled == nil {
+		return
+	}
+
+	if ui.Led != nil {
+		ui.Led.SetEnabled(*state.LedEnabled)
+
+		return
+	}
+
+	ui.ledOn = *state.LedEnabled
+
+	if ui.ledOn {
+		ui.Epd.OnLed()
+	}
+}
+
+// resumesPanel tells whether the panel was fully refreshed recently enough to skip clearing it on start
+func (ui *NasUI) resumesPanel(state State) bool {
+	maxAge := ui.refreshPolicy().MaxPartialAge
+
+	return maxAge > 0 && !state.LastFullRefresh.IsZero() && time.Since(state.LastFullRefresh) < maxAge
+}
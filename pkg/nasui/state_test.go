@@ -0,0 +1,117 @@
+package nasui
+
+import (
+	"nas-kit-ui/pkg/epd"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore keeps the State in memory
+type memoryStore struct {
+	mu    sync.Mutex
+	state State
+}
+
+func (s *memoryStore) Load() (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.state, nil
+}
+
+func (s *memoryStore) Save(state State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state = state
+
+	return nil
+}
+
+// runUntilDisplayed runs the ui until the first page is displayed and returns the calls of the panel
+func runUntilDisplayed(t *testing.T, ui *NasUI, sim *epd.Simulator) []epd.SimCall {
+	t.Helper()
+
+	stop := runUI(t, ui)
+	deadline := time.Now().Add(5 * time.Second)
+
+	for !hasDisplay(sim.Calls()) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	return sim.Calls()
+}
+
+func hasDisplay(calls []epd.SimCall) bool {
+	for _, call := range calls {
+		if call.Op == epd.SimOpDisplay && !call.Ignored {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clearedBeforeDisplay tells whether the panel was cleared before the first page was displayed
+func clearedBeforeDisplay(calls []epd.SimCall) bool {
+	for _, call := range calls {
+		switch call.Op {
+		case epd.SimOpClear:
+			return true
+		case epd.SimOpDisplay:
+			return false
+		}
+	}
+
+	return false
+}
+
+func TestRunResumesPanel(t *testing.T) {
+	ui, sim := newTestUI(t, "One", "Two")
+	store := &memoryStore{state: State{Page: "Two", LastFullRefresh: time.Now().Add(-time.Minute)}}
+	ui.StateStore = store
+
+	calls := runUntilDisplayed(t, ui, sim)
+
+	if clearedBeforeDisplay(calls) {
+		t.Error("the panel is cleared before the first page after a resume")
+	}
+
+	for _, call := range calls {
+		if call.Op == epd.SimOpDisplay && !call.Ignored {
+			if call.Partial {
+				t.Error("the first page after a resume is drawn with a partial refresh")
+			}
+
+			break
+		}
+	}
+
+	if page := store.state.Page; page != "Two" {
+		t.Errorf("saved page %q, want the restored one \"Two\"", page)
+	}
+}
+
+func TestRunClearsPanelWithoutResume(t *testing.T) {
+	tests := map[string]State{
+		"no state":        {},
+		"old refresh":     {LastFullRefresh: time.Now().Add(-time.Hour)},
+		"unknown refresh": {Page: "Two"},
+	}
+
+	for name, state := range tests {
+		t.Run(name, func(t *testing.T) {
+			ui, sim := newTestUI(t, "One", "Two")
+			ui.StateStore = &memoryStore{state: state}
+
+			if !clearedBeforeDisplay(runUntilDisplayed(t, ui, sim)) {
+				t.Error("the panel is not cleared before the first page")
+			}
+		})
+	}
+}